@@ -0,0 +1,209 @@
+package api
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"emboxd/letterboxd"
+	"emboxd/mapping"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUser is a configured user's media server identities and the
+// Letterboxd account they sync to, without any passwords or tokens
+type AdminUser struct {
+	Letterboxd   string `json:"letterboxd"`
+	EmbyUsername string `json:"emby_username,omitempty"`
+	PlexUsername string `json:"plex_username,omitempty"`
+	PlexID       string `json:"plex_id,omitempty"`
+	// Whether the user has a Plex token of their own
+	PlexToken   bool `json:"plex_token"`
+	LogFilms    bool `json:"log_films"`
+	SyncLikes   bool `json:"sync_likes"`
+	ReverseSync bool `json:"reverse_sync"`
+	DryRun      bool `json:"dry_run"`
+}
+
+// AdminWorker is the state of a Letterboxd worker and its queue
+type AdminWorker struct {
+	Username    string                   `json:"username"`
+	Paused      bool                     `json:"paused"`
+	Backlog     int                      `json:"backlog"`
+	Review      int                      `json:"review"`
+	Connected   bool                     `json:"connected"`
+	LastChecked time.Time                `json:"last_checked"`
+	Activity    letterboxd.Activity      `json:"activity"`
+	Breaker     letterboxd.BreakerStatus `json:"breaker"`
+}
+
+// RequireAdminToken protects the admin API with a bearer token
+func (a *Api) RequireAdminToken(token string) {
+	a.adminToken = token
+}
+
+// SetUsers sets the users listed on the admin API
+func (a *Api) SetUsers(users []AdminUser) {
+	a.adminUsers = users
+}
+
+// adminAuth checks the bearer token. Without a token configured the endpoints
+// it protects are disabled.
+func (a *Api) adminAuth() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if a.adminToken == "" {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "set admin.token to use the admin API"})
+			return
+		}
+
+		var token, ok = strings.CutPrefix(context.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+			context.Header("WWW-Authenticate", `Bearer realm="EmBoxd"`)
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing admin token"})
+		}
+	}
+}
+
+// readAuth checks the bearer token of read-only endpoints once a token is
+// configured. Without one they stay open, as they were before the admin API.
+func (a *Api) readAuth() gin.HandlerFunc {
+	var adminAuth = a.adminAuth()
+	return func(context *gin.Context) {
+		if a.adminToken != "" {
+			adminAuth(context)
+		}
+	}
+}
+
+// hasMapping returns true if a manual mapping exists for the film
+func (a *Api) hasMapping(ids mapping.Ids) bool {
+	if a.mappings == nil {
+		return false
+	}
+	_, ok := a.mappings.Resolve(ids)
+	return ok
+}
+
+// getMappings returns the manual IMDb/TMDb/item to Letterboxd mapping table
+func (a *Api) getMappings(context *gin.Context) {
+	context.JSON(http.StatusOK, a.mappings.Get())
+}
+
+// putMappings replaces the manual mapping table and persists it
+func (a *Api) putMappings(context *gin.Context) {
+	var mappings mapping.Mappings
+	if err := context.BindJSON(&mappings); err != nil {
+		return
+	}
+
+	if err := mappings.Validate(); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := a.mappings.Set(mappings); err != nil {
+		slog.Error("Failed to save mappings", slog.String("error", err.Error()))
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Updated manual mappings",
+		slog.Int("imdb", len(mappings.Imdb)),
+		slog.Int("tmdb", len(mappings.Tmdb)),
+		slog.Int("emby", len(mappings.Emby)),
+		slog.Int("plex", len(mappings.Plex)))
+	context.JSON(http.StatusOK, a.mappings.Get())
+}
+
+// getUsers returns the configured users
+func (a *Api) getUsers(context *gin.Context) {
+	users := a.adminUsers
+	if users == nil {
+		users = []AdminUser{}
+	}
+	context.JSON(http.StatusOK, users)
+}
+
+func adminWorker(username string, worker *letterboxd.Worker) AdminWorker {
+	status := worker.Status()
+	return AdminWorker{
+		Username:    username,
+		Paused:      worker.Paused(),
+		Backlog:     worker.Backlog(),
+		Review:      len(worker.ReviewQueue()),
+		Connected:   status.IsConnected,
+		LastChecked: status.LastChecked,
+		Activity:    worker.Activity(),
+		Breaker:     status.Breaker,
+	}
+}
+
+// getWorkers returns the state of every Letterboxd worker
+func (a *Api) getWorkers(context *gin.Context) {
+	workers := make([]AdminWorker, 0, len(a.letterboxdWorkers))
+	for username, worker := range a.letterboxdWorkers {
+		workers = append(workers, adminWorker(username, worker))
+	}
+	slices.SortFunc(workers, func(x, y AdminWorker) int {
+		return strings.Compare(x.Username, y.Username)
+	})
+	context.JSON(http.StatusOK, workers)
+}
+
+// getWorker returns the state of a Letterboxd worker
+func (a *Api) getWorker(context *gin.Context) {
+	worker, ok := a.letterboxdWorkers[context.Param("username")]
+	if !ok {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	context.JSON(http.StatusOK, adminWorker(context.Param("username"), worker))
+}
+
+// postWorkerControl pauses, resumes or logs in a Letterboxd worker again
+func (a *Api) postWorkerControl(control func(*letterboxd.Worker) error) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		worker, ok := a.letterboxdWorkers[context.Param("username")]
+		if !ok {
+			context.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		if err := control(worker); err != nil {
+			slog.Error("Failed to control Letterboxd worker",
+				slog.String("username", context.Param("username")),
+				slog.String("path", context.FullPath()),
+				slog.String("error", err.Error()))
+			context.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, adminWorker(context.Param("username"), worker))
+	}
+}
+
+// setupAdminRoutes sets up the admin API routes
+func (a *Api) setupAdminRoutes() {
+	adminRouter := a.router.Group("/admin")
+	adminRouter.Use(a.adminAuth())
+	adminRouter.GET("/mappings", a.getMappings)
+	adminRouter.PUT("/mappings", a.putMappings)
+	adminRouter.GET("/users", a.getUsers)
+	adminRouter.POST("/users/:username/films", a.postFilm)
+	adminRouter.GET("/workers", a.getWorkers)
+	adminRouter.GET("/workers/:username", a.getWorker)
+	adminRouter.POST("/workers/:username/pause", a.postWorkerControl(func(worker *letterboxd.Worker) error {
+		worker.Pause()
+		return nil
+	}))
+	adminRouter.POST("/workers/:username/resume", a.postWorkerControl(func(worker *letterboxd.Worker) error {
+		worker.Resume()
+		return nil
+	}))
+	adminRouter.POST("/workers/:username/relogin", a.postWorkerControl((*letterboxd.Worker).Relogin))
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/mapping"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAdminTestApi(t *testing.T, token string) http.Handler {
+	var mappings, err = mapping.NewStore(filepath.Join(t.TempDir(), "mappings.yaml"))
+	assert.NoError(t, err)
+
+	var app = New(
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*letterboxd.Worker{},
+		mappings,
+		history.NewStore(10),
+	)
+	if token != "" {
+		app.RequireAdminToken(token)
+	}
+	app.SetUsers([]AdminUser{
+		{Letterboxd: "john_doe", EmbyUsername: "john", PlexID: "12345", PlexToken: true, LogFilms: true},
+	})
+	return app.Handler()
+}
+
+func adminRequest(handler http.Handler, method string, path string, token string) *httptest.ResponseRecorder {
+	var request = httptest.NewRequest(method, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	var recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAdminAuth(t *testing.T) {
+	var tests = []struct {
+		name     string
+		token    string
+		method   string
+		path     string
+		with     string
+		expected int
+	}{
+		{"users without configured token", "", http.MethodGet, "/admin/users", "", http.StatusForbidden},
+		{"workers without configured token", "", http.MethodPost, "/admin/workers/john_doe/pause", "", http.StatusForbidden},
+		{"mappings without configured token", "", http.MethodGet, "/admin/mappings", "", http.StatusForbidden},
+		{"mappings update without configured token", "", http.MethodPut, "/admin/mappings", "", http.StatusForbidden},
+		{"users without token", "secret", http.MethodGet, "/admin/users", "", http.StatusUnauthorized},
+		{"users with wrong token", "secret", http.MethodGet, "/admin/users", "wrong", http.StatusUnauthorized},
+		{"users with token", "secret", http.MethodGet, "/admin/users", "secret", http.StatusOK},
+		{"mappings without token", "secret", http.MethodGet, "/admin/mappings", "", http.StatusUnauthorized},
+		{"mappings with token", "secret", http.MethodGet, "/admin/mappings", "secret", http.StatusOK},
+		{"unknown worker", "secret", http.MethodGet, "/admin/workers/jane_doe", "secret", http.StatusNotFound},
+		{"unknown worker relogin", "secret", http.MethodPost, "/admin/workers/jane_doe/relogin", "secret", http.StatusNotFound},
+		{"film without token", "secret", http.MethodPost, "/admin/users/john_doe/films", "", http.StatusUnauthorized},
+		{"film for unknown user", "secret", http.MethodPost, "/admin/users/jane_doe/films", "secret", http.StatusNotFound},
+		{"review without configured token", "", http.MethodGet, "/review", "", http.StatusForbidden},
+		{"review without token", "secret", http.MethodGet, "/review", "", http.StatusUnauthorized},
+		{"review with token", "secret", http.MethodGet, "/review", "secret", http.StatusOK},
+		{"review dismiss without token", "secret", http.MethodDelete, "/review/john_doe/tt0133093", "", http.StatusUnauthorized},
+		{"review dismiss for unknown user", "secret", http.MethodDelete, "/review/jane_doe/tt0133093", "secret", http.StatusNotFound},
+		{"review retry without token", "secret", http.MethodPost, "/review/john_doe/tt0133093/retry", "", http.StatusUnauthorized},
+		{"events without configured token", "", http.MethodGet, "/events", "", http.StatusOK},
+		{"events without token", "secret", http.MethodGet, "/events", "", http.StatusUnauthorized},
+		{"events with token", "secret", http.MethodGet, "/events", "secret", http.StatusOK},
+		{"event stream without token", "secret", http.MethodGet, "/events/stream", "", http.StatusUnauthorized},
+		{"sessions without configured token", "", http.MethodGet, "/sessions", "", http.StatusOK},
+		{"sessions without token", "secret", http.MethodGet, "/sessions", "", http.StatusUnauthorized},
+		{"sessions with token", "secret", http.MethodGet, "/sessions", "secret", http.StatusOK},
+		{"dashboard page without configured token", "", http.MethodGet, "/dashboard/", "", http.StatusOK},
+		{"dashboard overview without configured token", "", http.MethodGet, "/dashboard/api/overview", "", http.StatusForbidden},
+		{"dashboard events without configured token", "", http.MethodGet, "/dashboard/api/events", "", http.StatusForbidden},
+		{"dashboard review retry without configured token", "", http.MethodPost, "/dashboard/api/review/john_doe/tt0133093/retry", "", http.StatusForbidden},
+		{"dashboard review dismiss without configured token", "", http.MethodDelete, "/dashboard/api/review/john_doe/tt0133093", "", http.StatusForbidden},
+		{"dashboard overview without token", "secret", http.MethodGet, "/dashboard/api/overview", "", http.StatusUnauthorized},
+		{"dashboard overview with token", "secret", http.MethodGet, "/dashboard/api/overview", "secret", http.StatusOK},
+		{"dashboard review retry without token", "secret", http.MethodPost, "/dashboard/api/review/john_doe/tt0133093/retry", "", http.StatusUnauthorized},
+		{"dashboard review dismiss without token", "secret", http.MethodDelete, "/dashboard/api/review/john_doe/tt0133093", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var handler = newAdminTestApi(t, test.token)
+			assert.Equal(t, test.expected, adminRequest(handler, test.method, test.path, test.with).Code)
+		})
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	var handler = newAdminTestApi(t, "secret")
+	var response = adminRequest(handler, http.MethodGet, "/admin/users", "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.NotContains(t, response.Body.String(), "password")
+
+	var users []AdminUser
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &users))
+	assert.Equal(t, []AdminUser{
+		{Letterboxd: "john_doe", EmbyUsername: "john", PlexID: "12345", PlexToken: true, LogFilms: true},
+	}, users)
+}
+
+func TestManualFilmEvent(t *testing.T) {
+	var now = time.Date(2026, 10, 18, 21, 30, 0, 0, time.UTC)
+	var tests = []struct {
+		name   string
+		film   ManualFilm
+		action letterboxd.Action
+		time   time.Time
+		err    string
+	}{
+		{"log by default today", ManualFilm{ImdbId: "tt0133093"}, letterboxd.FilmLogged, now, ""},
+		{"date", ManualFilm{ImdbId: "tt0133093", Action: "watched", Date: "2026-10-01"}, letterboxd.FilmWatched, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), ""},
+		{"today keeps the time", ManualFilm{ImdbId: "tt0133093", Date: "2026-10-18"}, letterboxd.FilmLogged, now, ""},
+		{"unwatch", ManualFilm{ImdbId: "tt0133093", Action: "unwatch"}, letterboxd.FilmUnwatched, now, ""},
+		{"rating", ManualFilm{ImdbId: "tt0133093", Rating: 4.5}, letterboxd.FilmLogged, now, ""},
+		{"invalid imdb id", ManualFilm{ImdbId: "0133093"}, 0, time.Time{}, `invalid IMDb ID "0133093"`},
+		{"unknown action", ManualFilm{ImdbId: "tt0133093", Action: "like"}, 0, time.Time{}, `unknown action "like", expected watched, log or unwatch`},
+		{"invalid date", ManualFilm{ImdbId: "tt0133093", Date: "01/10/2026"}, 0, time.Time{}, `invalid date "01/10/2026", expected YYYY-MM-DD`},
+		{"future date", ManualFilm{ImdbId: "tt0133093", Date: "2026-10-19"}, 0, time.Time{}, "date 2026-10-19 is in the future"},
+		{"rating without log", ManualFilm{ImdbId: "tt0133093", Action: "watched", Rating: 4}, 0, time.Time{}, "a rating can only be given with the log action"},
+		{"quarter star rating", ManualFilm{ImdbId: "tt0133093", Rating: 3.25}, 0, time.Time{}, "invalid rating 3.25, expected half stars from 0.5 to 5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var event, err = test.film.event(now)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.action, event.Action)
+				assert.Equal(t, test.time, event.Time)
+				assert.Equal(t, "manual", event.Server)
+				assert.Equal(t, test.film.Rating, event.Rating)
+			}
+		})
+	}
+}
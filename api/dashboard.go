@@ -0,0 +1,146 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// DashboardOverview is everything the dashboard shows apart from the event timeline
+type DashboardOverview struct {
+	Workers  []DashboardWorker                  `json:"workers"`
+	Sessions []DashboardSession                 `json:"sessions"`
+	Review   map[string][]letterboxd.ReviewItem `json:"review"`
+}
+
+// DashboardWorker is a Letterboxd worker's state and queue
+type DashboardWorker struct {
+	LetterboxdWorkerState
+	Backlog int `json:"backlog"`
+}
+
+// DashboardSession is a film being played, or stopped before it was logged
+type DashboardSession struct {
+	Server             string    `json:"server"`
+	Username           string    `json:"username"`
+	ImdbId             string    `json:"imdb_id"`
+	Title              string    `json:"title"`
+	Year               int       `json:"year,omitempty"`
+	Player             string    `json:"player,omitempty"`
+	Device             string    `json:"device,omitempty"`
+	Playing            bool      `json:"playing"`
+	PositionSeconds    int       `json:"position_seconds"`
+	RuntimeSeconds     int       `json:"runtime_seconds"`
+	WatchedSeconds     int       `json:"watched_seconds"`
+	WatchedPercentage  uint      `json:"watched_percentage"`
+	PositionPercentage uint      `json:"position_percentage"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// RequireDashboardLogin protects the dashboard with HTTP basic authentication
+func (a *Api) RequireDashboardLogin(username string, password string) {
+	a.dashboardAccounts = gin.Accounts{username: password}
+}
+
+// processors returns every notification processor once, as users can be
+// configured for several media servers
+func (a *Api) processors() []*notification.Processor {
+	var processors []*notification.Processor
+	for _, byUser := range []map[string]*notification.Processor{
+		a.notificationProcessorByEmbyUsername,
+		a.notificationProcessorByPlexUsername,
+		a.notificationProcessorByPlexAccountID,
+	} {
+		for _, processor := range byUser {
+			if !slices.Contains(processors, processor) {
+				processors = append(processors, processor)
+			}
+		}
+	}
+	return processors
+}
+
+func dashboardSession(session notification.Session) DashboardSession {
+	return DashboardSession{
+		Server:             session.Server.String(),
+		Username:           session.Username,
+		ImdbId:             session.ImdbId,
+		Title:              session.Title,
+		Year:               session.Year,
+		Player:             session.Player,
+		Device:             session.Device,
+		Playing:            session.Playing,
+		PositionSeconds:    int(session.Position.Seconds()),
+		RuntimeSeconds:     int(session.Runtime.Seconds()),
+		WatchedSeconds:     int(session.Watched.Seconds()),
+		PositionPercentage: session.PositionPercentage(),
+		WatchedPercentage:  session.WatchedPercentage(),
+		UpdatedAt:          session.Time,
+	}
+}
+
+// getDashboardOverview returns the workers, sessions and failed syncs
+func (a *Api) getDashboardOverview(context *gin.Context) {
+	var health, _ = a.buildHealthStatus()
+	var overview = DashboardOverview{
+		Workers:  make([]DashboardWorker, 0, len(health.LetterboxdWorkers)),
+		Sessions: []DashboardSession{},
+		Review:   make(map[string][]letterboxd.ReviewItem, len(a.letterboxdWorkers)),
+	}
+	for _, state := range health.LetterboxdWorkers {
+		overview.Workers = append(overview.Workers, DashboardWorker{
+			LetterboxdWorkerState: state,
+			Backlog:               a.letterboxdWorkers[state.Username].Backlog(),
+		})
+		overview.Review[state.Username] = a.letterboxdWorkers[state.Username].ReviewQueue()
+	}
+	slices.SortFunc(overview.Workers, func(x, y DashboardWorker) int {
+		return strings.Compare(x.Username, y.Username)
+	})
+
+	for _, processor := range a.processors() {
+		for _, session := range processor.Sessions() {
+			overview.Sessions = append(overview.Sessions, dashboardSession(session))
+		}
+	}
+	slices.SortFunc(overview.Sessions, func(x, y DashboardSession) int {
+		return y.UpdatedAt.Compare(x.UpdatedAt)
+	})
+
+	context.JSON(http.StatusOK, overview)
+}
+
+// setupDashboardRoutes serves the dashboard page and the API it uses. Without
+// a dashboard login the page is public, but its API needs the admin token
+func (a *Api) setupDashboardRoutes() {
+	var dashboardRouter = a.router.Group("/dashboard")
+	if len(a.dashboardAccounts) > 0 {
+		dashboardRouter.Use(gin.BasicAuthForRealm(a.dashboardAccounts, "EmBoxd"))
+	}
+
+	var files, _ = fs.Sub(dashboardFiles, "dashboard")
+	dashboardRouter.GET("/", func(context *gin.Context) {
+		context.FileFromFS("/", http.FS(files))
+	})
+	dashboardRouter.StaticFS("/static", http.FS(files))
+
+	var apiRouter = dashboardRouter.Group("/api")
+	if len(a.dashboardAccounts) == 0 {
+		apiRouter.Use(a.adminAuth())
+	}
+	apiRouter.GET("/overview", a.getDashboardOverview)
+	apiRouter.GET("/events", a.getEvents)
+	apiRouter.POST("/review/:username/:imdb/retry", a.postRetryReview)
+	apiRouter.DELETE("/review/:username/:imdb", a.deleteReview)
+}
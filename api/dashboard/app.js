@@ -0,0 +1,165 @@
+'use strict';
+
+// Dashboard API, relative to /dashboard/ so it works behind a path prefix
+const API = 'api';
+const REFRESH_INTERVAL = 10000;
+
+function element(tag, attributes = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [name, value] of Object.entries(attributes)) {
+    if (name === 'onclick') {
+      node.addEventListener('click', value);
+    } else {
+      node.setAttribute(name, value);
+    }
+  }
+  for (const child of children) {
+    node.append(child instanceof Node ? child : String(child ?? ''));
+  }
+  return node;
+}
+
+function row(...cells) {
+  return element('tr', {}, ...cells.map((cell) => cell instanceof Node && cell.tagName === 'TD' ? cell : element('td', {}, cell)));
+}
+
+function fill(id, rows, columns, empty) {
+  const body = document.getElementById(id);
+  body.replaceChildren(...(rows.length ? rows : [element('tr', {}, element('td', { class: 'empty', colspan: columns }, empty))]));
+}
+
+function time(value) {
+  if (!value || value.startsWith('0001-')) {
+    return 'never';
+  }
+  return new Date(value).toLocaleString();
+}
+
+function duration(seconds) {
+  const hours = Math.floor(seconds / 3600);
+  const minutes = Math.floor(seconds / 60) % 60;
+  return `${hours}:${String(minutes).padStart(2, '0')}`;
+}
+
+function film(title, year, id) {
+  return [title || id, year ? ` (${year})` : ''].join('');
+}
+
+function bar(percentage) {
+  return element('div', { class: 'bar', title: `${percentage}%` }, element('span', { style: `width: ${Math.min(percentage, 100)}%` }));
+}
+
+// Admin token, asked for when the dashboard has no login of its own
+let adminToken = sessionStorage.getItem('adminToken');
+let askingForToken = false;
+
+async function request(path, options = {}) {
+  const headers = adminToken ? { Authorization: `Bearer ${adminToken}` } : {};
+  const response = await fetch(`${API}/${path}`, { ...options, headers });
+  if (response.status === 401 && !askingForToken) {
+    askingForToken = true;
+    adminToken = prompt('Admin token');
+    askingForToken = false;
+    if (adminToken) {
+      sessionStorage.setItem('adminToken', adminToken);
+      return request(path, options);
+    }
+    sessionStorage.removeItem('adminToken');
+  }
+  if (!response.ok) {
+    throw new Error(`${path}: ${response.status} ${response.statusText}`);
+  }
+  return response.status === 200 ? response.json() : null;
+}
+
+async function reviewAction(username, imdbId, retry) {
+  const path = `review/${encodeURIComponent(username)}/${encodeURIComponent(imdbId)}`;
+  await request(retry ? `${path}/retry` : path, { method: retry ? 'POST' : 'DELETE' });
+  refresh();
+}
+
+function renderOverview(overview) {
+  fill('workers', overview.workers.map((worker) => row(
+    worker.username,
+    element('td', { class: worker.connected ? 'ok' : 'error' }, worker.connected ? 'Logged in' : 'Logged out'),
+    element('td', { class: worker.breaker.state === 'closed' ? 'ok' : 'warning' }, worker.breaker.state),
+    worker.backlog,
+    time(worker.last_checked),
+  )), 5, 'No Letterboxd accounts configured');
+
+  fill('sessions', overview.sessions.map((session) => row(
+    `${session.username} (${session.server})`,
+    film(session.title, session.year, session.imdb_id),
+    [session.player, session.device].filter(Boolean).join(' – '),
+    element('td', {}, bar(session.position_percentage), `${duration(session.position_seconds)} / ${duration(session.runtime_seconds)}`),
+    element('td', {}, bar(session.watched_percentage), `${session.watched_percentage}%`),
+    element('td', { class: session.playing ? 'ok' : '' }, session.playing ? 'Playing' : time(session.updated_at)),
+  )), 6, 'Nothing playing');
+
+  const review = [];
+  for (const [username, items] of Object.entries(overview.review)) {
+    for (const item of items) {
+      review.push(row(
+        username,
+        film(item.expected_title, item.expected_year, item.imdb_id),
+        item.action,
+        element('td', { class: 'error', title: item.error }, item.reason),
+        time(item.queued_at),
+        element('td', {},
+          element('button', { onclick: () => reviewAction(username, item.imdb_id, true) }, 'Retry'), ' ',
+          element('button', { onclick: () => reviewAction(username, item.imdb_id, false) }, 'Dismiss'),
+        ),
+      ));
+    }
+  }
+  fill('review', review, 6, 'No failed syncs');
+}
+
+function decision(value) {
+  const { thresholds } = value;
+  return `${value.action} (${value.reason}, watched ${value.watched_percentage}%/${thresholds.watched_percentage}%, position ${value.position_percentage}%/${thresholds.position_percentage}%)`;
+}
+
+function renderEvents(response) {
+  fill('events', response.events.map((event) => {
+    const details = Object.entries(event.details || {}).map(([name, value]) => {
+      if (name === 'decision') {
+        return `decision: ${decision(value)}`;
+      }
+      return `${name}: ${typeof value === 'object' ? JSON.stringify(value) : value}`;
+    });
+    if (event.error_message) {
+      details.unshift(event.error_message);
+    }
+    return row(
+      time(event.timestamp),
+      event.type,
+      event.source,
+      event.username,
+      film(event.media_title, 0, event.media_id),
+      element('td', { class: { success: 'ok', error: 'error', skipped: 'warning' }[event.status] || '' }, event.status),
+      element('td', { class: 'details' }, details.join(', ')),
+    );
+  }), 7, 'No events');
+}
+
+async function refresh() {
+  const filters = new URLSearchParams(new FormData(document.getElementById('filters')));
+  for (const [name, value] of [...filters]) {
+    if (!value) {
+      filters.delete(name);
+    }
+  }
+  try {
+    const [overview, events] = await Promise.all([request('overview'), request(`events?${filters}`)]);
+    renderOverview(overview);
+    renderEvents(events);
+    document.getElementById('updated').textContent = `Updated ${new Date().toLocaleTimeString()}`;
+  } catch (error) {
+    document.getElementById('updated').textContent = `Update failed: ${error.message}`;
+  }
+}
+
+document.getElementById('filters').addEventListener('input', refresh);
+refresh();
+setInterval(refresh, REFRESH_INTERVAL);
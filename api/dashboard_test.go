@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDashboardTestApi(processor *notification.Processor, login bool) http.Handler {
+	var app = New(
+		map[string]*notification.Processor{"john": processor},
+		map[string]*notification.Processor{"John": processor},
+		map[string]*notification.Processor{},
+		map[string]*letterboxd.Worker{},
+		nil,
+		history.NewStore(10),
+	)
+	if login {
+		app.RequireDashboardLogin("admin", "secret")
+	} else {
+		app.RequireAdminToken("secret")
+	}
+	return app.Handler()
+}
+
+func get(handler http.Handler, path string, username string, password string) *httptest.ResponseRecorder {
+	var request = httptest.NewRequest(http.MethodGet, path, nil)
+	if username != "" {
+		request.SetBasicAuth(username, password)
+	}
+	var recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestDashboardServesPage(t *testing.T) {
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var handler = newDashboardTestApi(&processor, false)
+
+	var page = get(handler, "/dashboard/", "", "")
+	assert.Equal(t, http.StatusOK, page.Code)
+	assert.Contains(t, page.Body.String(), "<title>EmBoxd</title>")
+
+	var redirect = get(handler, "/dashboard", "", "")
+	assert.Equal(t, http.StatusMovedPermanently, redirect.Code)
+	assert.Equal(t, "/dashboard/", redirect.Header().Get("Location"))
+
+	var script = get(handler, "/dashboard/static/app.js", "", "")
+	assert.Equal(t, http.StatusOK, script.Code)
+}
+
+func TestDashboardOverviewSessions(t *testing.T) {
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var handler = newDashboardTestApi(&processor, false)
+
+	processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		Metadata: notification.Metadata{
+			Server:   notification.Emby,
+			Username: "john",
+			ImdbId:   "tt0133093",
+			Title:    "The Matrix",
+			Year:     1999,
+			Time:     time.Now().Add(-2 * time.Hour),
+		},
+		Playing:  true,
+		Position: 0,
+		Runtime:  136 * time.Minute,
+	})
+	processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		Metadata: notification.Metadata{
+			Server:   notification.Emby,
+			Username: "john",
+			ImdbId:   "tt0133093",
+			Title:    "The Matrix",
+			Year:     1999,
+			Time:     time.Now(),
+		},
+		Playing:  false,
+		Position: 68 * time.Minute,
+		Runtime:  136 * time.Minute,
+	})
+
+	var response = adminRequest(handler, http.MethodGet, "/dashboard/api/overview", "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	var overview DashboardOverview
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &overview))
+	if assert.Len(t, overview.Sessions, 1) {
+		var session = overview.Sessions[0]
+		assert.Equal(t, "emby", session.Server)
+		assert.Equal(t, "tt0133093", session.ImdbId)
+		assert.False(t, session.Playing)
+		assert.Equal(t, uint(50), session.PositionPercentage)
+		assert.Equal(t, uint(50), session.WatchedPercentage)
+	}
+}
+
+func TestDashboardLogin(t *testing.T) {
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var handler = newDashboardTestApi(&processor, true)
+
+	assert.Equal(t, http.StatusUnauthorized, get(handler, "/dashboard/", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(handler, "/dashboard/api/overview", "admin", "wrong").Code)
+	assert.Equal(t, http.StatusOK, get(handler, "/dashboard/api/overview", "admin", "secret").Code)
+}
+
+func TestDashboardOverviewSessionWithoutRuntime(t *testing.T) {
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var handler = newDashboardTestApi(&processor, false)
+
+	// Some clients don't report the runtime
+	processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		Metadata: notification.Metadata{Server: notification.Emby, Username: "john", ImdbId: "tt0133093", Time: time.Now()},
+		Playing:  true,
+		Position: 10 * time.Minute,
+	})
+
+	var response = adminRequest(handler, http.MethodGet, "/dashboard/api/overview", "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	var overview DashboardOverview
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &overview))
+	if assert.Len(t, overview.Sessions, 1) {
+		assert.Equal(t, uint(0), overview.Sessions[0].PositionPercentage)
+	}
+}
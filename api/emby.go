@@ -1,6 +1,8 @@
 package api
 
 import (
+	"emboxd/history"
+	"emboxd/mapping"
 	"emboxd/notification"
 	"log/slog"
 	"time"
@@ -17,12 +19,24 @@ type embyNotification struct {
 	User  struct {
 		Name string `json:"Name"`
 	} `json:"User"`
+	Session struct {
+		Client     string `json:"Client"`
+		DeviceName string `json:"DeviceName"`
+	} `json:"Session"`
 	Item struct {
-		Type         string `json:"Type"`
-		RuntimeTicks int64  `json:"RunTimeTicks"`
-		ProviderIds  struct {
+		Id             string `json:"Id"`
+		Name           string `json:"Name"`
+		ProductionYear int    `json:"ProductionYear"`
+		Height         int    `json:"Height"`
+		Type           string `json:"Type"`
+		RuntimeTicks   int64  `json:"RunTimeTicks"`
+		ProviderIds    struct {
 			Imdb string `json:"Imdb"`
+			Tmdb string `json:"Tmdb"`
 		} `json:"ProviderIds"`
+		UserData struct {
+			IsFavorite bool `json:"IsFavorite"`
+		} `json:"UserData"`
 	} `json:"Item"`
 	PlaybackInfo struct {
 		PlayedToCompletion bool   `json:"PlayedToCompletion"`
@@ -38,6 +52,8 @@ func convertTicksToDuration(ticks int64) time.Duration {
 }
 
 func (a *Api) postEmbyWebhook(context *gin.Context) {
+	startTime := time.Now()
+
 	// Track the webhook for metrics
 	a.metrics.TrackWebhook("emby")
 
@@ -56,8 +72,9 @@ func (a *Api) postEmbyWebhook(context *gin.Context) {
 		return
 	}
 
-	if embyNotif.Item.Type != "Movie" || embyNotif.Item.ProviderIds.Imdb == "" {
-		// Only handle movies and valid IMDB entries
+	var hasMapping = a.hasMapping(mapping.Ids{Tmdb: embyNotif.Item.ProviderIds.Tmdb, Server: "emby", Item: embyNotif.Item.Id})
+	if embyNotif.Item.Type != "Movie" || (embyNotif.Item.ProviderIds.Imdb == "" && !hasMapping) {
+		// Only handle movies and valid IMDB entries (or manually mapped ones)
 		slog.Debug("Media item is not a valid movie, ignoring notification", slog.Group("emby", "user", embyNotif.User.Name, "type", embyNotif.Item.Type), slog.Group("imdb", "id", embyNotif.Item.ProviderIds.Imdb))
 		context.AbortWithStatus(200)
 		return
@@ -73,48 +90,87 @@ func (a *Api) postEmbyWebhook(context *gin.Context) {
 		Server:   notification.Emby,
 		Username: embyNotif.User.Name,
 		ImdbId:   embyNotif.Item.ProviderIds.Imdb,
+		TmdbId:   embyNotif.Item.ProviderIds.Tmdb,
+		ItemId:   embyNotif.Item.Id,
+		Title:    embyNotif.Item.Name,
+		Year:     embyNotif.Item.ProductionYear,
 		Time:     eventTime,
+
+		Player:     embyNotif.Session.Client,
+		Device:     embyNotif.Session.DeviceName,
+		Resolution: notification.Resolution(embyNotif.Item.Height),
 	}
 
+	var eventType history.EventType
+	var decision notification.Decision
 	switch embyNotif.Event {
 	case "item.markplayed":
-		notificationProcessor.ProcessWatchedNotification(notification.WatchedNotification{
+		decision = notificationProcessor.ProcessWatchedNotification(notification.WatchedNotification{
 			Metadata: metadata,
 			Watched:  true,
 			Runtime:  convertTicksToDuration(embyNotif.Item.RuntimeTicks),
 		})
+		eventType = history.EventTypeWatched
 	case "item.markunplayed":
-		notificationProcessor.ProcessWatchedNotification(notification.WatchedNotification{
+		decision = notificationProcessor.ProcessWatchedNotification(notification.WatchedNotification{
 			Metadata: metadata,
 			Watched:  false,
 			Runtime:  convertTicksToDuration(embyNotif.Item.RuntimeTicks),
 		})
-	case "playback.start", "playback.unpause":
-		notificationProcessor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		eventType = history.EventTypeWatched
+	case "playback.start", "playback.unpause", "playback.progress":
+		decision = notificationProcessor.ProcessPlaybackNotification(notification.PlaybackNotification{
 			Metadata: metadata,
 			Playing:  true,
 			Position: convertTicksToDuration(embyNotif.PlaybackInfo.PositionTicks),
 			Runtime:  convertTicksToDuration(embyNotif.Item.RuntimeTicks),
 		})
+		eventType = history.EventTypePlayback
 	case "playback.stop", "playback.pause":
-		notificationProcessor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		decision = notificationProcessor.ProcessPlaybackNotification(notification.PlaybackNotification{
 			Metadata: metadata,
 			Playing:  false,
 			Position: convertTicksToDuration(embyNotif.PlaybackInfo.PositionTicks),
 			Runtime:  convertTicksToDuration(embyNotif.Item.RuntimeTicks),
 		})
+		eventType = history.EventTypePlayback
 
 		if embyNotif.PlaybackInfo.PlayedToCompletion {
-			notificationProcessor.ProcessWatchedNotification(notification.WatchedNotification{
+			decision = notificationProcessor.ProcessWatchedNotification(notification.WatchedNotification{
 				Metadata: metadata,
 				Watched:  true,
 				Runtime:  convertTicksToDuration(embyNotif.Item.RuntimeTicks),
 			})
 		}
+	case "item.rate", "item.favorite":
+		// User data changed, e.g. the film was favourited or unfavourited
+		decision = notificationProcessor.ProcessFavoriteNotification(notification.FavoriteNotification{
+			Metadata: metadata,
+			Favorite: embyNotif.Item.UserData.IsFavorite,
+		})
+		eventType = history.EventTypeFavorite
 	default:
-		context.AbortWithStatus(400)
+		slog.Debug("Unsupported Emby event, ignoring notification", slog.Group("emby", "user", embyNotif.User.Name, "event", embyNotif.Event))
+		context.AbortWithStatus(200)
 		return
 	}
+
+	a.logEvent(&history.Event{
+		ID:         history.GenerateID(),
+		Timestamp:  time.Now(),
+		Type:       eventType,
+		Source:     history.SourceEmby,
+		Username:   embyNotif.User.Name,
+		MediaID:    embyNotif.Item.ProviderIds.Imdb,
+		MediaTitle: embyNotif.Item.Name,
+		Status:     history.StatusSuccess,
+		Details: map[string]interface{}{
+			"event":                embyNotif.Event,
+			"played_to_completion": embyNotif.PlaybackInfo.PlayedToCompletion,
+			"decision":             decision,
+		},
+		ProcessingMs: int(time.Since(startTime).Milliseconds()),
+	})
 }
 
 func (a *Api) setupEmbyRoutes() {
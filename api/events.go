@@ -1,14 +1,22 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"emboxd/history"
 
 	"github.com/gin-gonic/gin"
 )
 
+// _EVENT_STREAM_HEARTBEAT_INTERVAL is how often an idle event stream sends a
+// comment, to keep proxies from closing the connection
+const _EVENT_STREAM_HEARTBEAT_INTERVAL time.Duration = 15 * time.Second
+
 // EventsResponse is the response format for the events endpoint
 type EventsResponse struct {
 	Total  int         `json:"total"`
@@ -25,8 +33,18 @@ func (a *Api) getEvents(context *gin.Context) {
 		limit = 25 // Default limit
 	}
 
-	// Get events from store
-	events := a.eventHistory.GetLatest(limit)
+	// Get events from store, optionally filtered by the query parameters
+	events := a.eventHistory.GetAll()
+	filtered := make([]*history.Event, 0, len(events))
+	for _, event := range events {
+		if matchesEventFilters(event, context) {
+			filtered = append(filtered, event)
+		}
+	}
+	events = filtered
+	if len(events) > limit {
+		events = events[:limit]
+	}
 
 	response := EventsResponse{
 		Total:  len(events),
@@ -37,6 +55,102 @@ func (a *Api) getEvents(context *gin.Context) {
 	context.JSON(http.StatusOK, response)
 }
 
+// matchesEventFilters returns true if the event matches the username, source,
+// type and status query parameters that are set
+func matchesEventFilters(event *history.Event, context *gin.Context) bool {
+	for param, value := range map[string]string{
+		"username": event.Username,
+		"source":   string(event.Source),
+		"type":     string(event.Type),
+		"status":   string(event.Status),
+	} {
+		if filter := context.Query(param); filter != "" && filter != value {
+			return false
+		}
+	}
+	return true
+}
+
+// getEventStream pushes new events as Server-Sent Events, using the sequence
+// number as the event ID so that clients can resume with Last-Event-ID
+func (a *Api) getEventStream(context *gin.Context) {
+	var lastSequence uint64
+	if lastEventID := context.GetHeader("Last-Event-ID"); lastEventID != "" {
+		var err error
+		if lastSequence, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			context.String(http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	// Subscribe before catching up, so that nothing is missed in between
+	var subscription = a.eventHistory.Subscribe(0)
+	defer subscription.Close()
+	if lastSequence > a.eventHistory.LastSequence() {
+		// Sequence numbers restart with the server
+		lastSequence = 0
+	}
+
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	context.Header("Connection", "keep-alive")
+	context.Header("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+
+	var send = func(event *history.Event) error {
+		if event.Sequence <= lastSequence {
+			return nil
+		}
+		lastSequence = event.Sequence
+		if !matchesEventFilters(event, context) {
+			return nil
+		}
+		return writeServerSentEvent(context.Writer, event)
+	}
+
+	if context.GetHeader("Last-Event-ID") != "" {
+		for _, event := range a.eventHistory.Since(lastSequence) {
+			if err := send(event); err != nil {
+				return
+			}
+		}
+	}
+	context.Writer.Flush()
+
+	var heartbeat = time.NewTicker(_EVENT_STREAM_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-context.Request.Context().Done():
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// Dropped for falling behind, the client reconnects and resumes
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(context.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		context.Writer.Flush()
+	}
+}
+
+// writeServerSentEvent writes an event in the text/event-stream format
+func writeServerSentEvent(writer io.Writer, event *history.Event) error {
+	var data, err = json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "id: %d\ndata: %s\n\n", event.Sequence, data)
+	return err
+}
+
 // logEvent adds an event to the history store
 func (a *Api) logEvent(event interface{}) {
 	if a.eventHistory != nil && event != nil {
@@ -51,5 +165,6 @@ func (a *Api) logEvent(event interface{}) {
 
 // setupEventsRoutes sets up the events API routes
 func (a *Api) setupEventsRoutes() {
-	a.router.GET("/events", a.getEvents)
+	a.router.GET("/events", a.readAuth(), a.getEvents)
+	a.router.GET("/events/stream", a.readAuth(), a.getEventStream)
 }
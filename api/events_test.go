@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readServerSentEvents reads events from a stream until count have arrived
+func readServerSentEvents(t *testing.T, reader *bufio.Reader, count int) []history.Event {
+	var events []history.Event
+	var id string
+	for len(events) < count {
+		var line, err = reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return events
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			var event history.Event
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+			assert.Equal(t, id, strconv.FormatUint(event.Sequence, 10))
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestEventStream(t *testing.T) {
+	var store = history.NewStore(10)
+	var app = New(
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*letterboxd.Worker{},
+		nil,
+		store,
+	)
+	var server = httptest.NewServer(app.Handler())
+	defer server.Close()
+
+	store.Add(&history.Event{Type: history.EventTypePlayback, Source: history.SourceEmby, Username: "john", MediaID: "tt0000001"})
+	store.Add(&history.Event{Type: history.EventTypeWatched, Source: history.SourcePlex, Username: "jane", MediaID: "tt0000002"})
+	store.Add(&history.Event{Type: history.EventTypeWatched, Source: history.SourceEmby, Username: "john", MediaID: "tt0000003"})
+
+	// Resume after the first event, only for john
+	var request, _ = http.NewRequest(http.MethodGet, server.URL+"/events/stream?username=john", nil)
+	request.Header.Set("Last-Event-ID", "1")
+	var response, err = http.DefaultClient.Do(request)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	var reader = bufio.NewReader(response.Body)
+	var events = readServerSentEvents(t, reader, 1)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "tt0000003", events[0].MediaID)
+	}
+
+	// New events are pushed as they are added
+	store.Add(&history.Event{Type: history.EventTypeSync, Source: history.SourceLetterboxd, Username: "jane", MediaID: "tt0000004"})
+	store.Add(&history.Event{Type: history.EventTypeSync, Source: history.SourceLetterboxd, Username: "john", MediaID: "tt0000005"})
+	events = readServerSentEvents(t, reader, 1)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "tt0000005", events[0].MediaID)
+		assert.Equal(t, uint64(5), events[0].Sequence)
+	}
+}
+
+func TestEventStreamInvalidLastEventID(t *testing.T) {
+	var app = New(
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*letterboxd.Worker{},
+		nil,
+		history.NewStore(10),
+	)
+	var request = httptest.NewRequest(http.MethodGet, "/events/stream", nil)
+	request.Header.Set("Last-Event-ID", "abc")
+	var recorder = httptest.NewRecorder()
+	app.Handler().ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"time"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+
+	"github.com/gin-gonic/gin"
+)
+
+var imdbIdPattern = regexp.MustCompile(`^tt\d+$`)
+
+// Actions that can be requested for a film logged by hand
+var _ACTION_BY_NAME = map[string]letterboxd.Action{
+	"watched": letterboxd.FilmWatched,
+	"log":     letterboxd.FilmLogged,
+	"unwatch": letterboxd.FilmUnwatched,
+}
+
+// ManualFilm is a film watched outside the media servers, e.g. at the cinema
+type ManualFilm struct {
+	ImdbId string `json:"imdb_id"`
+	Action string `json:"action"` // "watched", "log" or "unwatch", "log" if empty
+	// Date watched as YYYY-MM-DD, today if empty
+	Date string `json:"date,omitempty"`
+	// Stars from 0.5 to 5, only for "log"
+	Rating float64 `json:"rating,omitempty"`
+	// Checked against the Letterboxd film page if given
+	Title string `json:"title,omitempty"`
+	Year  int    `json:"year,omitempty"`
+}
+
+// event validates the film and returns the Letterboxd event for it
+func (f ManualFilm) event(now time.Time) (letterboxd.Event, error) {
+	if !imdbIdPattern.MatchString(f.ImdbId) {
+		return letterboxd.Event{}, fmt.Errorf("invalid IMDb ID %q", f.ImdbId)
+	}
+
+	if f.Action == "" {
+		f.Action = "log"
+	}
+	action, ok := _ACTION_BY_NAME[f.Action]
+	if !ok {
+		return letterboxd.Event{}, fmt.Errorf("unknown action %q, expected watched, log or unwatch", f.Action)
+	}
+
+	if f.Rating != 0 {
+		if action != letterboxd.FilmLogged {
+			return letterboxd.Event{}, fmt.Errorf("a rating can only be given with the log action")
+		}
+		if f.Rating < 0.5 || f.Rating > 5 || math.Mod(f.Rating*2, 1) != 0 {
+			return letterboxd.Event{}, fmt.Errorf("invalid rating %v, expected half stars from 0.5 to 5", f.Rating)
+		}
+	}
+
+	// Films watched today keep the time, so duplicate checks stay accurate
+	watched := now
+	if f.Date != "" {
+		date, err := time.ParseInLocation(time.DateOnly, f.Date, now.Location())
+		if err != nil {
+			return letterboxd.Event{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", f.Date)
+		}
+		if date.After(now) {
+			return letterboxd.Event{}, fmt.Errorf("date %s is in the future", f.Date)
+		}
+		if date.Format(time.DateOnly) != now.Format(time.DateOnly) {
+			watched = date
+		}
+	}
+
+	return letterboxd.Event{
+		Film: letterboxd.Film{
+			ImdbId: f.ImdbId,
+			Server: string(history.SourceManual),
+			Title:  f.Title,
+			Year:   f.Year,
+		},
+		Action: action,
+		Time:   watched,
+		Rating: f.Rating,
+	}, nil
+}
+
+// postFilm queues a film logged by hand on a Letterboxd account
+func (a *Api) postFilm(context *gin.Context) {
+	username := context.Param("username")
+	worker, ok := a.letterboxdWorkers[username]
+	if !ok {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	var film ManualFilm
+	if err := context.BindJSON(&film); err != nil {
+		return
+	}
+
+	event, err := film.event(a.now())
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Queueing film logged by hand",
+		slog.String("username", username),
+		slog.String("imdbId", event.ImdbId),
+		slog.String("action", event.Action.String()),
+		slog.Time("time", event.Time))
+	worker.HandleEvent(event)
+
+	a.logEvent(&history.Event{
+		ID:         history.GenerateID(),
+		Timestamp:  a.now(),
+		Type:       history.EventTypeWatched,
+		Source:     history.SourceManual,
+		Username:   username,
+		MediaID:    event.ImdbId,
+		MediaTitle: event.Title,
+		Status:     history.StatusReceived,
+		Details: map[string]interface{}{
+			"action": event.Action.String(),
+			"date":   event.Time.Format(time.DateOnly),
+			"rating": event.Rating,
+		},
+	})
+
+	context.JSON(http.StatusAccepted, gin.H{
+		"username": username,
+		"imdb_id":  event.ImdbId,
+		"action":   event.Action.String(),
+		"date":     event.Time.Format(time.DateOnly),
+		"rating":   event.Rating,
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"time"
 
+	"emboxd/letterboxd"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,50 +14,76 @@ type HealthStatus struct {
 	Status            string                  `json:"status"`
 	Uptime            string                  `json:"uptime"`
 	StartTime         time.Time               `json:"start_time"`
+	BrowserConnected  bool                    `json:"browser_connected"`
 	LetterboxdWorkers []LetterboxdWorkerState `json:"letterboxd_workers"`
 }
 
 // LetterboxdWorkerState represents the status of a Letterboxd worker
 type LetterboxdWorkerState struct {
-	Username    string    `json:"username"`
-	Connected   bool      `json:"connected"`
-	LastChecked time.Time `json:"last_checked"`
+	Username    string                   `json:"username"`
+	Connected   bool                     `json:"connected"`
+	LastChecked time.Time                `json:"last_checked"`
+	Breaker     letterboxd.BreakerStatus `json:"breaker"`
 }
 
 var startTime = time.Now()
 
-func (a *Api) getHealth(context *gin.Context) {
+// buildHealthStatus assembles the health status from the workers' cached
+// probe results, so it never opens a browser page itself
+func (a *Api) buildHealthStatus() (HealthStatus, bool) {
 	status := HealthStatus{
-		Status:    "ok",
-		Uptime:    time.Since(startTime).Round(time.Second).String(),
-		StartTime: startTime,
+		Status:           "ok",
+		Uptime:           time.Since(startTime).Round(time.Second).String(),
+		StartTime:        startTime,
+		BrowserConnected: letterboxd.BrowserConnected(),
 	}
 
 	// Check Letterboxd workers status
 	status.LetterboxdWorkers = make([]LetterboxdWorkerState, 0, len(a.letterboxdWorkers))
-	allConnected := true
+	ready := status.BrowserConnected
 
 	for username, worker := range a.letterboxdWorkers {
-		workerStatus := worker.CheckStatus()
+		workerStatus := worker.Status()
 
 		workerState := LetterboxdWorkerState{
 			Username:    username,
 			Connected:   workerStatus.IsConnected,
 			LastChecked: workerStatus.LastChecked,
+			Breaker:     workerStatus.Breaker,
 		}
 
 		status.LetterboxdWorkers = append(status.LetterboxdWorkers, workerState)
 
-		if !workerStatus.IsConnected {
-			allConnected = false
+		if !workerStatus.IsConnected || workerStatus.Breaker.State != letterboxd.BreakerClosed {
+			ready = false
 		}
 	}
 
-	// If any Letterboxd worker is not connected, set status to warn
-	if !allConnected {
+	// If the browser or any Letterboxd worker is down, set status to warn
+	if !ready {
 		status.Status = "warning"
 	}
 
+	return status, ready
+}
+
+func (a *Api) getHealth(context *gin.Context) {
+	status, _ := a.buildHealthStatus()
+	context.JSON(http.StatusOK, status)
+}
+
+// getLivez reports that the process is up and serving requests
+func (a *Api) getLivez(context *gin.Context) {
+	context.String(http.StatusOK, "ok")
+}
+
+// getReadyz reports whether the browser and every Letterboxd account are usable
+func (a *Api) getReadyz(context *gin.Context) {
+	status, ready := a.buildHealthStatus()
+	if !ready {
+		context.JSON(http.StatusServiceUnavailable, status)
+		return
+	}
 	context.JSON(http.StatusOK, status)
 }
 
@@ -65,4 +93,9 @@ func (a *Api) setupHealthRoutes() {
 	a.router.HEAD("/health", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
+
+	a.router.GET("/livez", a.getLivez)
+	a.router.HEAD("/livez", a.getLivez)
+	a.router.GET("/readyz", a.getReadyz)
+	a.router.HEAD("/readyz", a.getReadyz)
 }
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthProbes(t *testing.T) {
+	var app = New(
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{},
+		map[string]*letterboxd.Worker{},
+		nil,
+		history.NewStore(10),
+	)
+	var handler = app.Handler()
+
+	// No browser is running in tests, so the server isn't ready
+	var tests = []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{http.MethodGet, "/livez", http.StatusOK},
+		{http.MethodHead, "/livez", http.StatusOK},
+		{http.MethodGet, "/readyz", http.StatusServiceUnavailable},
+		{http.MethodHead, "/readyz", http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			var recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+			assert.Equal(t, test.expected, recorder.Code)
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"emboxd/letterboxd"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -74,6 +76,8 @@ type MetricsData struct {
 	RequestsByPath     map[string]int64  `json:"requests_by_path"`
 	AverageTimeByPath  map[string]int64  `json:"avg_time_by_path_ms"`
 	MemoryStats        map[string]uint64 `json:"memory_stats"`
+
+	LetterboxdBreakers map[string]letterboxd.BreakerStatus `json:"letterboxd_breakers"`
 }
 
 // GetMetricsData returns the current metrics
@@ -135,6 +139,14 @@ func MetricsMiddleware(metrics *Metrics) gin.HandlerFunc {
 // setupMetricsRoutes sets up metrics endpoints
 func (a *Api) setupMetricsRoutes() {
 	a.router.GET("/metrics", func(c *gin.Context) {
-		c.JSON(http.StatusOK, a.metrics.GetMetricsData())
+		data := a.metrics.GetMetricsData()
+
+		// Breaker state lives on the workers rather than in request metrics
+		data.LetterboxdBreakers = make(map[string]letterboxd.BreakerStatus, len(a.letterboxdWorkers))
+		for username, worker := range a.letterboxdWorkers {
+			data.LetterboxdBreakers[username] = worker.BreakerStatus()
+		}
+
+		c.JSON(http.StatusOK, data)
 	})
 }
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"io"
 	"log/slog"
+	"strings"
 	"time"
 
+	"emboxd/capture"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -57,9 +60,11 @@ func LoggingMiddleware() gin.HandlerFunc {
 			}
 		}
 
-		// Create custom writer
+		// Create custom writer, unless the response is never logged
 		w := &responseWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
-		c.Writer = w
+		if !shouldSkipResponseBodyLogging(path) {
+			c.Writer = w
+		}
 
 		// Process request
 		c.Next()
@@ -108,10 +113,32 @@ func LoggingMiddleware() gin.HandlerFunc {
 	}
 }
 
+// CaptureMiddleware records raw webhook requests for replaying later
+func CaptureMiddleware(recorder *capture.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != "POST" || !strings.HasSuffix(c.Request.URL.Path, "/webhook") {
+			c.Next()
+			return
+		}
+
+		var received = time.Now()
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+		}
+		if err := recorder.Record(capture.NewRequest(c.Request, body, received)); err != nil {
+			slog.Warn("Failed to record webhook", slog.String("path", c.Request.URL.Path), slog.String("error", err.Error()))
+		}
+		c.Next()
+	}
+}
+
 // shouldSkipRequestBodyLogging returns true if request body logging should be skipped
 func shouldSkipRequestBodyLogging(path string) bool {
 	// Skip health checks
-	if path == "/health" {
+	switch path {
+	case "/health", "/livez", "/readyz":
 		return true
 	}
 	return false
@@ -119,8 +146,9 @@ func shouldSkipRequestBodyLogging(path string) bool {
 
 // shouldSkipResponseBodyLogging returns true if response body logging should be skipped
 func shouldSkipResponseBodyLogging(path string) bool {
-	// Skip health checks
-	if path == "/health" {
+	// Skip health checks, and event streams that never end
+	switch path {
+	case "/health", "/livez", "/readyz", "/events/stream":
 		return true
 	}
 	return false
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"emboxd/history"
+	"emboxd/mapping"
 	"emboxd/notification"
 
 	"github.com/gin-gonic/gin"
@@ -21,9 +22,9 @@ type plexNotification struct {
 	User  bool   `json:"user"`
 	Owner bool   `json:"owner"`
 	Account struct {
-		ID    int    `json:"id"`
-		Title string `json:"title"`
-		Thumb string `json:"thumb,omitempty"`
+		ID    plexAccountID `json:"id"`
+		Title string        `json:"title"`
+		Thumb string        `json:"thumb,omitempty"`
 	} `json:"Account"`
 	Server struct {
 		Title string `json:"title"`
@@ -33,84 +34,16 @@ type plexNotification struct {
 		Local         bool   `json:"local"`
 		PublicAddress string `json:"publicAddress"`
 		Title         string `json:"title"`
+		Product       string `json:"product,omitempty"`
 		UUID          string `json:"uuid"`
 	} `json:"Player"`
-	Metadata struct {
-		LibrarySectionType   string `json:"librarySectionType"`
-		RatingKey            string `json:"ratingKey"`
-		Key                  string `json:"key"`
-		ParentRatingKey      string `json:"parentRatingKey,omitempty"`
-		GrandparentRatingKey string `json:"grandparentRatingKey,omitempty"`
-		Guid                 []struct {
-			ID string `json:"id"`
-		} `json:"Guid"`
-		GuidString           string `json:"guid,omitempty"`
-		LibrarySectionID     int    `json:"librarySectionID"`
-		Type                 string `json:"type"`
-		Title                string `json:"title"`
-		GrandparentKey       string `json:"grandparentKey,omitempty"`
-		ParentKey            string `json:"parentKey,omitempty"`
-		GrandparentTitle     string `json:"grandparentTitle,omitempty"`
-		ParentTitle          string `json:"parentTitle,omitempty"`
-		Summary              string `json:"summary"`
-		Index                int    `json:"index,omitempty"`
-		ParentIndex          int    `json:"parentIndex,omitempty"`
-		RatingCount          int    `json:"ratingCount,omitempty"`
-		Thumb                string `json:"thumb,omitempty"`
-		Art                  string `json:"art,omitempty"`
-		ParentThumb          string `json:"parentThumb,omitempty"`
-		GrandparentThumb     string `json:"grandparentThumb,omitempty"`
-		GrandparentArt       string `json:"grandparentArt,omitempty"`
-		AddedAt              int64  `json:"addedAt"`
-		UpdatedAt            int64  `json:"updatedAt"`
-		Duration             int64  `json:"duration,omitempty"`
-		ViewOffset           int64  `json:"viewOffset,omitempty"`
-	} `json:"Metadata"`
-}
-
-// parsePlexImdbId takes an array of Guid objects and returns the first valid IMDb ID found
-func parsePlexImdbId(guids []struct{ ID string `json:"id"` }) string {
-	if guids == nil {
-		return ""
-	}
-
-	// Check each GUID for an IMDb ID
-	for _, g := range guids {
-		guid := g.ID
-		// Direct IMDb format: "imdb://tt1234567"
-		const imdbPrefix = "imdb://"
-		if len(guid) > len(imdbPrefix) && guid[:len(imdbPrefix)] == imdbPrefix {
-			return guid[len(imdbPrefix):]
-		}
-
-		// TMDb format: "tmdb://12345"
-		// In a real implementation, this would do an API lookup to convert TMDb ID to IMDb ID
-		const tmdbPrefix = "tmdb://"
-		if len(guid) > len(tmdbPrefix) && guid[:len(tmdbPrefix)] == tmdbPrefix {
-			// For now, just return the TMDb ID as a fallback
-			// In a full implementation, you would:
-			// 1. Extract the TMDb ID: tmdbId := guid[len(tmdbPrefix):]
-			// 2. Use TMDb API to look up the corresponding IMDb ID
-			// 3. Return the IMDb ID
-			return guid[len(tmdbPrefix):]
-		}
-
-		// Plex internal format: "plex://movie/5d776b9..."
-		const plexPrefix = "plex://"
-		if len(guid) > len(plexPrefix) && guid[:len(plexPrefix)] == plexPrefix {
-			// For now, just log and continue to next GUID
-			slog.Debug("Plex internal ID found in GUID array, checking next available ID",
-				slog.String("plex_guid", guid))
-			continue
-		}
-	}
+	Metadata plexMetadata `json:"Metadata"`
 
-	// No valid IMDb ID found in any of the GUIDs
-	return ""
+	// Unix time of the event, when the payload includes it
+	EventTime int64 `json:"EventTime,omitempty"`
 }
 
-// extractImdbId tries to get an IMDb ID from both the Guid array and GuidString fields
-func extractImdbId(metadata struct {
+type plexMetadata struct {
 	LibrarySectionType   string `json:"librarySectionType"`
 	RatingKey            string `json:"ratingKey"`
 	Key                  string `json:"key"`
@@ -119,46 +52,104 @@ func extractImdbId(metadata struct {
 	Guid                 []struct {
 		ID string `json:"id"`
 	} `json:"Guid"`
-	GuidString           string `json:"guid,omitempty"`
-	LibrarySectionID     int    `json:"librarySectionID"`
-	Type                 string `json:"type"`
-	Title                string `json:"title"`
-	GrandparentKey       string `json:"grandparentKey,omitempty"`
-	ParentKey            string `json:"parentKey,omitempty"`
-	GrandparentTitle     string `json:"grandparentTitle,omitempty"`
-	ParentTitle          string `json:"parentTitle,omitempty"`
-	Summary              string `json:"summary"`
-	Index                int    `json:"index,omitempty"`
-	ParentIndex          int    `json:"parentIndex,omitempty"`
-	RatingCount          int    `json:"ratingCount,omitempty"`
-	Thumb                string `json:"thumb,omitempty"`
-	Art                  string `json:"art,omitempty"`
-	ParentThumb          string `json:"parentThumb,omitempty"`
-	GrandparentThumb     string `json:"grandparentThumb,omitempty"`
-	GrandparentArt       string `json:"grandparentArt,omitempty"`
-	AddedAt              int64  `json:"addedAt"`
-	UpdatedAt            int64  `json:"updatedAt"`
-	Duration             int64  `json:"duration,omitempty"`
-	ViewOffset           int64  `json:"viewOffset,omitempty"`
-}) string {
-	// First, try to get IMDb ID from the Guid array (preferred)
-	imdbId := parsePlexImdbId(metadata.Guid)
-	if imdbId != "" {
-		return imdbId
+	GuidString       string `json:"guid,omitempty"`
+	LibrarySectionID int    `json:"librarySectionID"`
+	Type             string `json:"type"`
+	Title            string `json:"title"`
+	Year             int    `json:"year,omitempty"`
+	GrandparentKey   string `json:"grandparentKey,omitempty"`
+	ParentKey        string `json:"parentKey,omitempty"`
+	GrandparentTitle string `json:"grandparentTitle,omitempty"`
+	ParentTitle      string `json:"parentTitle,omitempty"`
+	Summary          string `json:"summary"`
+	Index            int    `json:"index,omitempty"`
+	ParentIndex      int    `json:"parentIndex,omitempty"`
+	RatingCount      int    `json:"ratingCount,omitempty"`
+	Thumb            string `json:"thumb,omitempty"`
+	Art              string `json:"art,omitempty"`
+	ParentThumb      string `json:"parentThumb,omitempty"`
+	GrandparentThumb string `json:"grandparentThumb,omitempty"`
+	GrandparentArt   string `json:"grandparentArt,omitempty"`
+	AddedAt          int64  `json:"addedAt"`
+	UpdatedAt        int64  `json:"updatedAt"`
+	Duration         int64  `json:"duration,omitempty"`
+	ViewOffset       int64  `json:"viewOffset,omitempty"`
+
+	LibrarySectionTitle string  `json:"librarySectionTitle,omitempty"`
+	UserRating          float64 `json:"userRating,omitempty"`
+	LastViewedAt        int64   `json:"lastViewedAt,omitempty"`
+	Media               []struct {
+		VideoResolution string `json:"videoResolution"`
+		Height          int    `json:"height"`
+	} `json:"Media,omitempty"`
+}
+
+// Min Plex user rating (out of 10) for a film to count as liked, i.e. 4 stars
+const _PLEX_LIKE_RATING float64 = 8
+
+// resolution returns a resolution label such as "1080p" for the item's first media
+func (m plexMetadata) resolution() string {
+	if len(m.Media) == 0 {
+		return ""
 	}
+	return notification.PlexResolution(m.Media[0].VideoResolution, m.Media[0].Height)
+}
 
-	// If no IMDb ID found in array, try the GuidString field
-	if metadata.GuidString != "" {
-		// Create a single-item array to reuse the existing parsing logic
-		singleGuid := []struct{ ID string `json:"id"` }{
-			{ID: metadata.GuidString},
-		}
-		return parsePlexImdbId(singleGuid)
+// parsePlexGuid returns the ID from a Plex GUID if it uses the given agent prefix
+func parsePlexGuid(guid string, prefix string) string {
+	if len(guid) > len(prefix) && guid[:len(prefix)] == prefix {
+		return guid[len(prefix):]
 	}
+	return ""
+}
+
+// parsePlexImdbId returns the IMDb ID from a Plex GUID such as "imdb://tt1234567"
+func parsePlexImdbId(guid string) string {
+	return parsePlexGuid(guid, "imdb://")
+}
+
+// parsePlexTmdbId returns the TMDb ID from a Plex GUID such as "tmdb://12345"
+func parsePlexTmdbId(guid string) string {
+	return parsePlexGuid(guid, "tmdb://")
+}
 
+// guids returns every GUID for the item, preferring the Guid array over the legacy guid string
+func (m plexMetadata) guids() []string {
+	var guids = make([]string, 0, len(m.Guid)+1)
+	for _, g := range m.Guid {
+		guids = append(guids, g.ID)
+	}
+	if m.GuidString != "" {
+		guids = append(guids, m.GuidString)
+	}
+	return guids
+}
+
+// extractId returns the first ID found in the item's GUIDs by the given parser
+func (m plexMetadata) extractId(parse func(string) string) string {
+	for _, guid := range m.guids() {
+		if id := parse(guid); id != "" {
+			return id
+		}
+		if parsePlexGuid(guid, "plex://") != "" {
+			// Plex internal IDs need an API lookup, check the next available ID
+			slog.Debug("Plex internal ID found in GUIDs, checking next available ID",
+				slog.String("plex_guid", guid))
+		}
+	}
 	return ""
 }
 
+// extractImdbId tries to get an IMDb ID from both the Guid array and GuidString fields
+func extractImdbId(metadata plexMetadata) string {
+	return metadata.extractId(parsePlexImdbId)
+}
+
+// extractTmdbId tries to get a TMDb ID from both the Guid array and GuidString fields
+func extractTmdbId(metadata plexMetadata) string {
+	return metadata.extractId(parsePlexTmdbId)
+}
+
 func (a *Api) postPlexWebhook(context *gin.Context) {
 	startTime := time.Now()
 
@@ -209,13 +200,14 @@ func (a *Api) postPlexWebhook(context *gin.Context) {
 		return
 	}
 
-	// Only handle movies with IMDB id
+	// Only handle movies with IMDB id or a manual mapping
 	if plexNotif.Metadata.Type != "movie" {
 		context.AbortWithStatus(200)
 		return
 	}
 	imdbId := extractImdbId(plexNotif.Metadata)
-	if imdbId == "" {
+	tmdbId := extractTmdbId(plexNotif.Metadata)
+	if imdbId == "" && !a.hasMapping(mapping.Ids{Tmdb: tmdbId, Server: "plex", Item: plexNotif.Metadata.RatingKey}) {
 		context.AbortWithStatus(200)
 		return
 	}
@@ -228,10 +220,8 @@ func (a *Api) postPlexWebhook(context *gin.Context) {
 	var processor *notification.Processor
 	var ok bool
 
-	if accountID != 0 {
-		// Convert int ID to string for lookup
-		accountIDStr := fmt.Sprintf("%d", accountID)
-		processor, ok = a.notificationProcessorByPlexAccountID[accountIDStr]
+	if accountID != "" {
+		processor, ok = a.notificationProcessorByPlexAccountID[string(accountID)]
 	}
 
 	// Fall back to username matching if needed
@@ -241,20 +231,31 @@ func (a *Api) postPlexWebhook(context *gin.Context) {
 
 	if !ok {
 		slog.Debug("No Letterboxd account for Plex user, ignoring notification",
-			slog.Group("plex", "user", username, "accountID", accountID))
+			slog.Group("plex", "user", username, "accountID", string(accountID)))
 		context.AbortWithStatus(200)
 		return
 	}
-	// Use current time since Plex webhooks don't include eventTime
-	eventTime := time.Now()
+	// Plex webhooks rarely include the event time, so derive it from the
+	// payload where possible rather than relying on delivery being prompt
+	eventTime, timeSource := a.plexClock.eventTime(plexNotif, a.now())
 	metadata := notification.Metadata{
 		Server:   notification.Plex,
 		Username: username,
 		ImdbId:   imdbId,
+		TmdbId:   tmdbId,
+		ItemId:   plexNotif.Metadata.RatingKey,
+		Title:    plexNotif.Metadata.Title,
+		Year:     plexNotif.Metadata.Year,
 		Time:     eventTime,
+
+		Player:     plexNotif.Player.Product,
+		Device:     plexNotif.Player.Title,
+		Library:    plexNotif.Metadata.LibrarySectionTitle,
+		Resolution: plexNotif.Metadata.resolution(),
 	}
 
 	var eventType history.EventType
+	var decision notification.Decision
 
 	switch plexNotif.Event {
 	case "media.scrobble":
@@ -263,7 +264,7 @@ func (a *Api) postPlexWebhook(context *gin.Context) {
 			Watched:  true,
 			Runtime:  time.Duration(plexNotif.Metadata.Duration) * time.Millisecond,
 		}
-		processor.ProcessWatchedNotification(watched)
+		decision = processor.ProcessWatchedNotification(watched)
 		eventType = history.EventTypeWatched
 	case "media.play", "media.resume":
 		playback := notification.PlaybackNotification{
@@ -272,7 +273,7 @@ func (a *Api) postPlexWebhook(context *gin.Context) {
 			Position: time.Duration(plexNotif.Metadata.ViewOffset) * time.Millisecond,
 			Runtime:  time.Duration(plexNotif.Metadata.Duration) * time.Millisecond,
 		}
-		processor.ProcessPlaybackNotification(playback)
+		decision = processor.ProcessPlaybackNotification(playback)
 		eventType = history.EventTypePlayback
 	case "media.pause", "media.stop":
 		playback := notification.PlaybackNotification{
@@ -281,8 +282,16 @@ func (a *Api) postPlexWebhook(context *gin.Context) {
 			Position: time.Duration(plexNotif.Metadata.ViewOffset) * time.Millisecond,
 			Runtime:  time.Duration(plexNotif.Metadata.Duration) * time.Millisecond,
 		}
-		processor.ProcessPlaybackNotification(playback)
+		decision = processor.ProcessPlaybackNotification(playback)
 		eventType = history.EventTypePlayback
+	case "media.rate":
+		// Plex has no favourites for films, a high rating counts as a like
+		favorite := notification.FavoriteNotification{
+			Metadata: metadata,
+			Favorite: plexNotif.Metadata.UserRating >= _PLEX_LIKE_RATING,
+		}
+		decision = processor.ProcessFavoriteNotification(favorite)
+		eventType = history.EventTypeFavorite
 	default:
 		context.AbortWithStatus(400)
 		return
@@ -299,8 +308,11 @@ func (a *Api) postPlexWebhook(context *gin.Context) {
 		MediaTitle: plexNotif.Metadata.Title,
 		Status:     history.StatusSuccess,
 		Details: map[string]interface{}{
-			"event":  plexNotif.Event,
-			"server": plexNotif.Server.Title,
+			"event":       plexNotif.Event,
+			"server":      plexNotif.Server.Title,
+			"event_time":  eventTime,
+			"time_source": timeSource,
+			"decision":    decision,
 		},
 		ProcessingMs: int(time.Since(startTime).Milliseconds()),
 	}
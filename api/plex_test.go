@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -51,15 +52,15 @@ func TestParsePlexImdbId(t *testing.T) {
 
 func TestPlexNotificationParsing(t *testing.T) {
 	// Test cases with fixture files
-	fixtures := []string{
-		"testdata/plex_play_imdb.json",
-		"testdata/plex_pause_imdb.json",
-		"testdata/plex_scrobble_imdb.json",
-		"testdata/plex_play_tmdb.json",
-		"testdata/plex_play_plex.json",
+	fixtures := map[string]string{
+		"testdata/plex_play_imdb.json":     "imdb://tt0133093",
+		"testdata/plex_pause_imdb.json":    "imdb://tt0133093",
+		"testdata/plex_scrobble_imdb.json": "imdb://tt0133093",
+		"testdata/plex_play_tmdb.json":     "tmdb://27205",
+		"testdata/plex_play_plex.json":     "plex://movie/5d776b9da7dcad001f89e688",
 	}
 
-	for _, fixture := range fixtures {
+	for fixture, guid := range fixtures {
 		t.Run(fixture, func(t *testing.T) {
 			// Load fixture
 			data, err := os.ReadFile(fixture)
@@ -74,10 +75,98 @@ func TestPlexNotificationParsing(t *testing.T) {
 			assert.NotEmpty(t, notification.Event)
 			assert.NotEmpty(t, notification.Account.Title)
 			assert.NotEmpty(t, notification.Metadata.Title)
-			assert.NotEmpty(t, notification.Metadata.Guid)
+			assert.Equal(t, guid, notification.Metadata.GuidString)
+			assert.Equal(t, []string{guid}, notification.Metadata.guids())
 			assert.Greater(t, notification.Metadata.Duration, int64(0))
 			assert.NotEmpty(t, notification.Server.Title)
 			assert.Greater(t, notification.EventTime, int64(0))
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestPlexAccountIdParsing(t *testing.T) {
+	for _, payload := range []string{`{"Account": {"id": 12345}}`, `{"Account": {"id": "12345"}}`} {
+		var notification plexNotification
+		assert.NoError(t, json.Unmarshal([]byte(payload), &notification))
+		assert.Equal(t, plexAccountID("12345"), notification.Account.ID)
+	}
+}
+
+func TestPlexClock(t *testing.T) {
+	var received = time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC)
+	var notif = func(event string, viewOffset int64) plexNotification {
+		var notification plexNotification
+		notification.Event = event
+		notification.Account.ID = "12345"
+		notification.Metadata.RatingKey = "42"
+		notification.Metadata.ViewOffset = viewOffset
+		return notification
+	}
+
+	t.Run("Server event time", func(t *testing.T) {
+		var clock = newPlexClock()
+		var play = notif("media.play", 0)
+		play.EventTime = 1688159000
+		var eventTime, source = clock.eventTime(play, received)
+		assert.Equal(t, time.Unix(1688159000, 0), eventTime)
+		assert.Equal(t, _PLEX_TIME_EVENT, source)
+	})
+
+	t.Run("Scrobble uses last viewed time", func(t *testing.T) {
+		var clock = newPlexClock()
+		var scrobble = notif("media.scrobble", 7400000)
+		scrobble.Metadata.LastViewedAt = 1688159000
+		var eventTime, source = clock.eventTime(scrobble, received)
+		assert.Equal(t, time.Unix(1688159000, 0), eventTime)
+		assert.Equal(t, _PLEX_TIME_LAST_VIEWED, source)
+	})
+
+	t.Run("Late pause uses view offset delta", func(t *testing.T) {
+		var clock = newPlexClock()
+		var start = received.Add(-3 * time.Hour)
+		var playTime, playSource = clock.eventTime(notif("media.play", 600000), start)
+		assert.Equal(t, start, playTime)
+		assert.Equal(t, _PLEX_TIME_RECEIVED, playSource)
+
+		// Paused an hour in, but delivered two hours later
+		var pauseTime, pauseSource = clock.eventTime(notif("media.pause", 4200000), received)
+		assert.Equal(t, start.Add(time.Hour), pauseTime)
+		assert.Equal(t, _PLEX_TIME_VIEW_OFFSET, pauseSource)
+
+		// Nothing is known about how long it stayed paused
+		var resumeTime, resumeSource = clock.eventTime(notif("media.resume", 4200000), received)
+		assert.Equal(t, received, resumeTime)
+		assert.Equal(t, _PLEX_TIME_RECEIVED, resumeSource)
+	})
+
+	t.Run("Skipping ahead falls back to receipt", func(t *testing.T) {
+		var clock = newPlexClock()
+		clock.eventTime(notif("media.play", 0), received.Add(-time.Minute))
+		var eventTime, source = clock.eventTime(notif("media.stop", 3600000), received)
+		assert.Equal(t, received, eventTime)
+		assert.Equal(t, _PLEX_TIME_RECEIVED, source)
+	})
+
+	t.Run("Abandoned playback is forgotten", func(t *testing.T) {
+		var clock = newPlexClock()
+		clock.eventTime(notif("media.play", 0), received.Add(-2*_PLEX_PLAYBACK_MAX_AGE))
+		clock.eventTime(notif("media.pause", 600000), received.Add(-2*_PLEX_PLAYBACK_MAX_AGE+10*time.Minute))
+		assert.Len(t, clock.playbackByKey, 1)
+
+		var other = notif("media.play", 0)
+		other.Account.ID = "67890"
+		clock.eventTime(other, received)
+		assert.Len(t, clock.playbackByKey, 1)
+		assert.Contains(t, clock.playbackByKey, "67890//42")
+	})
+
+	t.Run("Other users are tracked separately", func(t *testing.T) {
+		var clock = newPlexClock()
+		clock.eventTime(notif("media.play", 0), received.Add(-time.Hour))
+		var other = notif("media.pause", 600000)
+		other.Account.ID = "67890"
+		var eventTime, source = clock.eventTime(other, received)
+		assert.Equal(t, received, eventTime)
+		assert.Equal(t, _PLEX_TIME_RECEIVED, source)
+	})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Sources of a Plex event's time, stored on its history event as time_source
+const (
+	_PLEX_TIME_EVENT       string = "event_time"     // Reported by the server in the payload
+	_PLEX_TIME_LAST_VIEWED string = "last_viewed_at" // When the server counted the view
+	_PLEX_TIME_VIEW_OFFSET string = "view_offset"    // Playback start plus the position advanced since
+	_PLEX_TIME_RECEIVED    string = "received"       // When the webhook arrived
+)
+
+// plexAccountID accepts the account ID as a number, as sent by Plex, or as a
+// string, as sent by some relays
+type plexAccountID string
+
+func (id *plexAccountID) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*id = plexAccountID(str)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	*id = plexAccountID(number.String())
+	return nil
+}
+
+// Playback events older than this are forgotten, e.g. of items paused and
+// never stopped
+const _PLEX_PLAYBACK_MAX_AGE time.Duration = 24 * time.Hour
+
+// plexPlayback is the last playback event for a user and item
+type plexPlayback struct {
+	time    time.Time
+	offset  time.Duration
+	playing bool
+}
+
+// plexClock works out when Plex events happened, since webhooks don't say and
+// may arrive late or be replayed
+type plexClock struct {
+	lock          sync.Mutex
+	playbackByKey map[string]plexPlayback
+}
+
+func newPlexClock() *plexClock {
+	return &plexClock{playbackByKey: make(map[string]plexPlayback)}
+}
+
+// eventTime returns the best known time of the event and its source, falling
+// back to the time it was received
+func (c *plexClock) eventTime(notif plexNotification, received time.Time) (time.Time, string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.expire(received)
+
+	var key = string(notif.Account.ID) + "/" + notif.Account.Title + "/" + notif.Metadata.RatingKey
+	var offset = time.Duration(notif.Metadata.ViewOffset) * time.Millisecond
+	var last, hasLast = c.playbackByKey[key]
+
+	var eventTime, source = received, _PLEX_TIME_RECEIVED
+	switch {
+	case notif.EventTime > 0:
+		eventTime, source = time.Unix(notif.EventTime, 0), _PLEX_TIME_EVENT
+	case notif.Event == "media.scrobble" && notif.Metadata.LastViewedAt > 0:
+		eventTime, source = time.Unix(notif.Metadata.LastViewedAt, 0), _PLEX_TIME_LAST_VIEWED
+	case (notif.Event == "media.pause" || notif.Event == "media.stop") && hasLast && last.playing && offset >= last.offset:
+		// Playback ran from the last event until now, unless skipped ahead,
+		// in which case the derived time would be later than the receipt
+		if derived := last.time.Add(offset - last.offset); !derived.After(received) {
+			eventTime, source = derived, _PLEX_TIME_VIEW_OFFSET
+		}
+	}
+
+	switch notif.Event {
+	case "media.play", "media.resume":
+		c.playbackByKey[key] = plexPlayback{time: eventTime, offset: offset, playing: true}
+	case "media.pause":
+		c.playbackByKey[key] = plexPlayback{time: eventTime, offset: offset, playing: false}
+	case "media.stop":
+		delete(c.playbackByKey, key)
+	}
+	return eventTime, source
+}
+
+// expire forgets playback events too old to derive times from, the clock
+// must be locked
+func (c *plexClock) expire(now time.Time) {
+	for key, playback := range c.playbackByKey {
+		if now.Sub(playback.time) > _PLEX_PLAYBACK_MAX_AGE {
+			delete(c.playbackByKey, key)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	"emboxd/letterboxd"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getReview returns the events held for review, keyed by Letterboxd username
+func (a *Api) getReview(context *gin.Context) {
+	queues := make(map[string][]letterboxd.ReviewItem, len(a.letterboxdWorkers))
+	for username, worker := range a.letterboxdWorkers {
+		queues[username] = worker.ReviewQueue()
+	}
+
+	context.JSON(http.StatusOK, queues)
+}
+
+// deleteReview dismisses a held event without acting on it
+func (a *Api) deleteReview(context *gin.Context) {
+	worker, ok := a.letterboxdWorkers[context.Param("username")]
+	if !ok {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if !worker.DismissReview(context.Param("imdb")) {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+// postRetryReview queues a held event again, e.g. after adding a mapping
+func (a *Api) postRetryReview(context *gin.Context) {
+	worker, ok := a.letterboxdWorkers[context.Param("username")]
+	if !ok {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if !worker.RetryReview(context.Param("imdb")) {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	context.Status(http.StatusAccepted)
+}
+
+// setupReviewRoutes sets up the review queue API routes
+func (a *Api) setupReviewRoutes() {
+	a.router.GET("/review", a.adminAuth(), a.getReview)
+	a.router.DELETE("/review/:username/:imdb", a.adminAuth(), a.deleteReview)
+	a.router.POST("/review/:username/:imdb/retry", a.adminAuth(), a.postRetryReview)
+}
@@ -3,9 +3,12 @@ package api
 import (
 	"fmt"
 	"log/slog"
+	"time"
 
+	"emboxd/capture"
 	"emboxd/history"
 	"emboxd/letterboxd"
+	"emboxd/mapping"
 	"emboxd/notification"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +22,13 @@ type Api struct {
 	letterboxdWorkers                    map[string]*letterboxd.Worker
 	eventHistory                         *history.Store
 	metrics                              *Metrics
+	mappings                             *mapping.Store
+	plexClock                            *plexClock
+	recorder                             *capture.Recorder
+	now                                  func() time.Time // Receipt time of the request being handled
+	dashboardAccounts                    gin.Accounts
+	adminToken                           string
+	adminUsers                           []AdminUser
 }
 
 func New(
@@ -26,7 +36,8 @@ func New(
 	notificationProcessorByPlexUsername,
 	notificationProcessorByPlexAccountID map[string]*notification.Processor,
 	letterboxdWorkers map[string]*letterboxd.Worker,
-	historySize int,
+	mappings *mapping.Store,
+	eventHistory *history.Store,
 ) Api {
 	gin.SetMode(gin.ReleaseMode)
 
@@ -45,21 +56,43 @@ func New(
 		notificationProcessorByPlexUsername:  notificationProcessorByPlexUsername,
 		notificationProcessorByPlexAccountID: notificationProcessorByPlexAccountID,
 		letterboxdWorkers:                    letterboxdWorkers,
-		eventHistory:                         history.NewStore(historySize),
+		eventHistory:                         eventHistory,
 		metrics:                              metrics,
+		mappings:                             mappings,
+		plexClock:                            newPlexClock(),
+		now:                                  time.Now,
 	}
 }
 
+// RecordWebhooks captures every webhook request received from now on
+func (a *Api) RecordWebhooks(recorder *capture.Recorder) {
+	a.recorder = recorder
+}
+
+// SetClock replaces the receipt time of requests, e.g. when replaying them
+func (a *Api) SetClock(now func() time.Time) {
+	a.now = now
+}
+
 func (a *Api) getRoot(context *gin.Context) {
 	context.String(200, "Welcome to EmBoxd!")
 }
 
 func (a *Api) setupRoutes() {
+	if a.recorder != nil {
+		a.router.Use(CaptureMiddleware(a.recorder))
+	}
+
 	a.setupEmbyRoutes()
 	a.setupPlexRoutes()
+	a.setupTautulliRoutes()
 	a.setupHealthRoutes()
 	a.setupEventsRoutes()
 	a.setupMetricsRoutes()
+	a.setupReviewRoutes()
+	a.setupSessionsRoutes()
+	a.setupAdminRoutes()
+	a.setupDashboardRoutes()
 
 	a.router.GET("/", a.getRoot)
 }
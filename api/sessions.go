@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+
+	"emboxd/notification"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaybackSession is a film being played, or stopped before it was logged,
+// with the thresholds it is logged at
+type PlaybackSession struct {
+	DashboardSession
+	ID         string                  `json:"id"` // IMDb ID or "tmdb:<id>"
+	Thresholds notification.Thresholds `json:"thresholds"`
+	// Parts of the runtime watched, and not watched before the position, as
+	// [start, end] in seconds
+	WatchedRanges [][2]int `json:"watched_ranges"`
+	SkippedRanges [][2]int `json:"skipped_ranges"`
+	// Whether stopping at the current position would log the film
+	Loggable bool `json:"loggable"`
+}
+
+// secondRanges returns intervals as [start, end] in seconds
+func secondRanges(intervals []notification.Interval) [][2]int {
+	var ranges = make([][2]int, 0, len(intervals))
+	for _, interval := range intervals {
+		ranges = append(ranges, [2]int{int(interval.Start.Seconds()), int(interval.End.Seconds())})
+	}
+	return ranges
+}
+
+// getSessions returns the films with playback that hasn't been logged yet,
+// keyed by media server username
+func (a *Api) getSessions(context *gin.Context) {
+	var thresholds = notification.LoggingThresholds()
+	var sessions = make(map[string][]PlaybackSession)
+	for _, processor := range a.processors() {
+		for _, session := range processor.Sessions() {
+			sessions[session.Username] = append(sessions[session.Username], PlaybackSession{
+				DashboardSession: dashboardSession(session),
+				ID:               session.ID,
+				Thresholds:       thresholds,
+				WatchedRanges:    secondRanges(session.Coverage),
+				SkippedRanges:    secondRanges(session.Coverage.Gaps(session.Position)),
+				Loggable: session.PositionPercentage() >= thresholds.Position &&
+					session.WatchedPercentage() >= thresholds.Watched,
+			})
+		}
+	}
+	for _, userSessions := range sessions {
+		slices.SortFunc(userSessions, func(x, y PlaybackSession) int {
+			return y.UpdatedAt.Compare(x.UpdatedAt)
+		})
+	}
+
+	context.JSON(http.StatusOK, sessions)
+}
+
+// postCommitSession logs a session's film even though not enough was watched,
+// e.g. after the media server missed the end of playback
+func (a *Api) postCommitSession(context *gin.Context) {
+	for _, processor := range a.processors() {
+		if processor.CommitSession(context.Param("username"), context.Param("imdb")) {
+			context.Status(http.StatusAccepted)
+			return
+		}
+	}
+
+	context.AbortWithStatus(http.StatusNotFound)
+}
+
+// deleteSession forgets a session's playback without logging its film
+func (a *Api) deleteSession(context *gin.Context) {
+	for _, processor := range a.processors() {
+		if processor.DiscardSession(context.Param("username"), context.Param("imdb")) {
+			context.Status(http.StatusNoContent)
+			return
+		}
+	}
+
+	context.AbortWithStatus(http.StatusNotFound)
+}
+
+// setupSessionsRoutes sets up the playback sessions API routes
+func (a *Api) setupSessionsRoutes() {
+	a.router.GET("/sessions", a.readAuth(), a.getSessions)
+	a.router.POST("/sessions/:username/:imdb/commit", a.adminAuth(), a.postCommitSession)
+	a.router.DELETE("/sessions/:username/:imdb", a.adminAuth(), a.deleteSession)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSessionsTestApi(processor *notification.Processor, token string) http.Handler {
+	var app = New(
+		map[string]*notification.Processor{"john": processor},
+		map[string]*notification.Processor{"John": processor},
+		map[string]*notification.Processor{},
+		map[string]*letterboxd.Worker{},
+		nil,
+		history.NewStore(10),
+	)
+	if token != "" {
+		app.RequireAdminToken(token)
+	}
+	return app.Handler()
+}
+
+// playHalf plays the first half of The Matrix on Emby as john
+func playHalf(processor *notification.Processor) {
+	var metadata = notification.Metadata{
+		Server:   notification.Emby,
+		Username: "john",
+		ImdbId:   "tt0133093",
+		Title:    "The Matrix",
+		Year:     1999,
+		Time:     time.Now().Add(-2 * time.Hour),
+	}
+	processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		Metadata: metadata,
+		Playing:  true,
+		Position: 0,
+		Runtime:  136 * time.Minute,
+	})
+	metadata.Time = time.Now()
+	processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		Metadata: metadata,
+		Playing:  false,
+		Position: 68 * time.Minute,
+		Runtime:  136 * time.Minute,
+	})
+}
+
+func TestGetSessions(t *testing.T) {
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var handler = newSessionsTestApi(&processor, "secret")
+	playHalf(&processor)
+
+	var response = adminRequest(handler, http.MethodGet, "/sessions", "secret")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	var sessions map[string][]PlaybackSession
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &sessions))
+	if assert.Len(t, sessions["john"], 1) {
+		var session = sessions["john"][0]
+		assert.Equal(t, "tt0133093", session.ID)
+		assert.Equal(t, 68*60, session.WatchedSeconds)
+		assert.Equal(t, uint(50), session.PositionPercentage)
+		assert.Equal(t, notification.LoggingThresholds(), session.Thresholds)
+		assert.Equal(t, [][2]int{{0, 68 * 60}}, session.WatchedRanges)
+		assert.Empty(t, session.SkippedRanges)
+		assert.False(t, session.Loggable)
+	}
+}
+
+func TestResolveSession(t *testing.T) {
+	var tests = []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		expected int
+		logged   bool
+		remains  bool
+	}{
+		{"commit", http.MethodPost, "/sessions/john/tt0133093/commit", "secret", http.StatusAccepted, true, false},
+		{"discard", http.MethodDelete, "/sessions/john/tt0133093", "secret", http.StatusNoContent, false, false},
+		{"commit for another user", http.MethodPost, "/sessions/jane/tt0133093/commit", "secret", http.StatusNotFound, false, true},
+		{"discard unknown film", http.MethodDelete, "/sessions/john/tt0234215", "secret", http.StatusNotFound, false, true},
+		{"commit without admin token", http.MethodPost, "/sessions/john/tt0133093/commit", "", http.StatusUnauthorized, false, true},
+		{"discard without admin token", http.MethodDelete, "/sessions/john/tt0133093", "", http.StatusUnauthorized, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events []letterboxd.Event
+			var processor = notification.NewProcessor(func(event letterboxd.Event) {
+				events = append(events, event)
+			})
+			var handler = newSessionsTestApi(&processor, "secret")
+			playHalf(&processor)
+
+			assert.Equal(t, test.expected, adminRequest(handler, test.method, test.path, test.token).Code)
+			if test.logged {
+				if assert.Len(t, events, 1) {
+					assert.Equal(t, letterboxd.FilmLogged, events[0].Action)
+					assert.Equal(t, "tt0133093", events[0].ImdbId)
+				}
+			} else {
+				assert.Empty(t, events)
+			}
+			assert.Equal(t, test.remains, len(processor.Sessions()) == 1)
+		})
+	}
+}
+
+func TestResolveSessionWithoutConfiguredToken(t *testing.T) {
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var handler = newSessionsTestApi(&processor, "")
+	playHalf(&processor)
+
+	assert.Equal(t, http.StatusForbidden, adminRequest(handler, http.MethodPost, "/sessions/john/tt0133093/commit", "").Code)
+	assert.Equal(t, http.StatusForbidden, adminRequest(handler, http.MethodDelete, "/sessions/john/tt0133093", "").Code)
+	assert.Len(t, processor.Sessions(), 1)
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"emboxd/history"
+	"emboxd/mapping"
+	"emboxd/notification"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tautulliValue accepts both strings and numbers, since Tautulli substitutes
+// parameters into the payload template as plain text
+type tautulliValue string
+
+func (v *tautulliValue) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*v = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*v = tautulliValue(strings.TrimSpace(str))
+		return nil
+	}
+	*v = tautulliValue(data)
+	return nil
+}
+
+func (v tautulliValue) int64() int64 {
+	var number, _ = strconv.ParseFloat(string(v), 64)
+	return int64(number)
+}
+
+// Tautulli JSON notification agent payload, as produced by the recommended
+// template in the README
+type tautulliNotification struct {
+	Action          string        `json:"action"`
+	UserId          tautulliValue `json:"user_id"`
+	Username        string        `json:"username"`
+	MediaType       string        `json:"media_type"`
+	Title           string        `json:"title"`
+	Year            tautulliValue `json:"year"`
+	ImdbId          string        `json:"imdb_id"`
+	TmdbId          tautulliValue `json:"themoviedb_id"`
+	RatingKey       tautulliValue `json:"rating_key"`
+	ViewOffset      tautulliValue `json:"view_offset"` // Milliseconds
+	Duration        tautulliValue `json:"duration"`    // Milliseconds
+	Timestamp       tautulliValue `json:"timestamp"`   // Unix time
+	Player          string        `json:"player"`
+	Product         string        `json:"product"`
+	Library         string        `json:"library_name"`
+	VideoResolution string        `json:"video_resolution"`
+	Server          string        `json:"server_name"`
+}
+
+func (a *Api) postTautulliWebhook(context *gin.Context) {
+	startTime := time.Now()
+
+	// Track the webhook for metrics
+	a.metrics.TrackWebhook("tautulli")
+
+	var tautulliNotif tautulliNotification
+	if err := context.BindJSON(&tautulliNotif); err != nil {
+		slog.Error("Malformed Tautulli webhook notification payload", slog.String("error", err.Error()))
+		a.logEvent(&history.Event{
+			ID:           history.GenerateID(),
+			Timestamp:    time.Now(),
+			Type:         history.EventTypeWebhook,
+			Source:       history.SourceTautulli,
+			Status:       history.StatusError,
+			ErrorMessage: err.Error(),
+			ProcessingMs: int(time.Since(startTime).Milliseconds()),
+		})
+		return
+	}
+
+	// Only handle movies with IMDB id or a manual mapping
+	if tautulliNotif.MediaType != "movie" {
+		context.AbortWithStatus(200)
+		return
+	}
+	var imdbId = tautulliNotif.ImdbId
+	var tmdbId = string(tautulliNotif.TmdbId)
+	var ratingKey = string(tautulliNotif.RatingKey)
+	if imdbId == "" && !a.hasMapping(mapping.Ids{Tmdb: tmdbId, Server: "plex", Item: ratingKey}) {
+		context.AbortWithStatus(200)
+		return
+	}
+
+	// Tautulli reports Plex users, so the Plex user maps apply
+	var processor, ok = a.notificationProcessorByPlexAccountID[string(tautulliNotif.UserId)]
+	if !ok {
+		processor, ok = a.notificationProcessorByPlexUsername[tautulliNotif.Username]
+	}
+	if !ok {
+		slog.Debug("No Letterboxd account for Tautulli user, ignoring notification",
+			slog.Group("tautulli", "user", tautulliNotif.Username, "userId", string(tautulliNotif.UserId)))
+		context.AbortWithStatus(200)
+		return
+	}
+
+	var eventTime = a.now()
+	if timestamp := tautulliNotif.Timestamp.int64(); timestamp > 0 {
+		eventTime = time.Unix(timestamp, 0)
+	}
+	var metadata = notification.Metadata{
+		Server:   notification.Plex,
+		Username: tautulliNotif.Username,
+		ImdbId:   imdbId,
+		TmdbId:   tmdbId,
+		ItemId:   ratingKey,
+		Title:    tautulliNotif.Title,
+		Year:     int(tautulliNotif.Year.int64()),
+		Time:     eventTime,
+
+		Player:     tautulliNotif.Product,
+		Device:     tautulliNotif.Player,
+		Library:    tautulliNotif.Library,
+		Resolution: notification.PlexResolution(tautulliNotif.VideoResolution, 0),
+	}
+	var position = time.Duration(tautulliNotif.ViewOffset.int64()) * time.Millisecond
+	var runtime = time.Duration(tautulliNotif.Duration.int64()) * time.Millisecond
+	if runtime <= 0 {
+		slog.Warn("Tautulli notification without a duration, ignoring", slog.String("imdbId", imdbId))
+		context.AbortWithStatus(200)
+		return
+	}
+
+	var eventType history.EventType
+	var decision notification.Decision
+	switch tautulliNotif.Action {
+	case "watched":
+		decision = processor.ProcessWatchedNotification(notification.WatchedNotification{
+			Metadata: metadata,
+			Watched:  true,
+			Runtime:  runtime,
+		})
+		eventType = history.EventTypeWatched
+	case "play", "resume":
+		decision = processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+			Metadata: metadata,
+			Playing:  true,
+			Position: position,
+			Runtime:  runtime,
+		})
+		eventType = history.EventTypePlayback
+	case "pause", "stop":
+		decision = processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+			Metadata: metadata,
+			Playing:  false,
+			Position: position,
+			Runtime:  runtime,
+		})
+		eventType = history.EventTypePlayback
+	default:
+		slog.Debug("Unsupported Tautulli action, ignoring notification", slog.String("action", tautulliNotif.Action))
+		context.AbortWithStatus(200)
+		return
+	}
+
+	a.logEvent(&history.Event{
+		ID:         history.GenerateID(),
+		Timestamp:  time.Now(),
+		Type:       eventType,
+		Source:     history.SourceTautulli,
+		Username:   tautulliNotif.Username,
+		MediaID:    imdbId,
+		MediaTitle: tautulliNotif.Title,
+		Status:     history.StatusSuccess,
+		Details: map[string]interface{}{
+			"event":    tautulliNotif.Action,
+			"server":   tautulliNotif.Server,
+			"decision": decision,
+		},
+		ProcessingMs: int(time.Since(startTime).Milliseconds()),
+	})
+
+	context.Status(200)
+}
+
+func (a *Api) setupTautulliRoutes() {
+	tautulliRouter := a.router.Group("/tautulli")
+	tautulliRouter.POST("/webhook", a.postTautulliWebhook)
+}
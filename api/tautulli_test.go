@@ -0,0 +1,77 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTautulliTestApi returns a handler with a single Plex user whose
+// Letterboxd events are collected in events
+func newTautulliTestApi(events *[]letterboxd.Event) http.Handler {
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		*events = append(*events, event)
+	})
+	var app = New(
+		map[string]*notification.Processor{},
+		map[string]*notification.Processor{"JohnDoe": &processor},
+		map[string]*notification.Processor{"12345": &processor},
+		map[string]*letterboxd.Worker{},
+		nil,
+		history.NewStore(10),
+	)
+	return app.Handler()
+}
+
+func postTautulliFixture(t *testing.T, handler http.Handler, fixture string) int {
+	var data, err = os.ReadFile(fixture)
+	assert.NoError(t, err)
+
+	var request = httptest.NewRequest(http.MethodPost, "/tautulli/webhook", bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+	var recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestTautulliPlaybackLogsFilm(t *testing.T) {
+	var events []letterboxd.Event
+	var handler = newTautulliTestApi(&events)
+
+	assert.Equal(t, 200, postTautulliFixture(t, handler, "testdata/tautulli_play.json"))
+	assert.Empty(t, events)
+	assert.Equal(t, 200, postTautulliFixture(t, handler, "testdata/tautulli_stop.json"))
+
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, letterboxd.FilmLogged, events[0].Action)
+		assert.Equal(t, "tt0133093", events[0].ImdbId)
+		assert.Equal(t, "42", events[0].ItemId)
+		assert.Equal(t, "plex", events[0].Server)
+		assert.Equal(t, "Living Room", events[0].Device)
+		assert.Equal(t, "1080p", events[0].Resolution)
+		assert.Equal(t, time.Unix(1792106400, 0), events[0].Time)
+	}
+}
+
+func TestTautulliWatched(t *testing.T) {
+	var events []letterboxd.Event
+	var handler = newTautulliTestApi(&events)
+
+	// Values quoted as strings by the template are accepted too
+	assert.Equal(t, 200, postTautulliFixture(t, handler, "testdata/tautulli_watched.json"))
+
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, letterboxd.FilmWatched, events[0].Action)
+		assert.Equal(t, 1999, events[0].Year)
+		assert.Equal(t, "4k", events[0].Resolution)
+	}
+}
@@ -0,0 +1,153 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"emboxd/logging"
+)
+
+// Size a capture file grows to before it's rotated, in megabytes
+const _MAX_FILE_SIZE_MB int64 = 10
+
+// Rotated capture files kept alongside the current one
+const _MAX_BACKUPS int = 5
+
+// Largest captured request Read accepts
+const _MAX_LINE_SIZE int = 16 * 1024 * 1024
+
+// Header and query parameter names containing any of these are never captured
+var secretNames = []string{"token", "key", "secret", "password", "auth", "cookie"}
+
+// Request is a captured webhook request
+type Request struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header"`
+	// Body as text, or base64 for binary bodies such as Plex thumbnails
+	Body       string `json:"body,omitempty"`
+	BodyBase64 []byte `json:"body_base64,omitempty"`
+}
+
+// NewRequest captures a request with the given body, which the caller has
+// already read, leaving out secrets
+func NewRequest(r *http.Request, body []byte, at time.Time) Request {
+	var request = Request{
+		Time:   at,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  redactQuery(r.URL.Query()).Encode(),
+		Header: make(http.Header),
+	}
+	for name, values := range r.Header {
+		if !isSecret(name) {
+			request.Header[name] = values
+		}
+	}
+	if utf8.Valid(body) {
+		request.Body = string(body)
+	} else {
+		request.BodyBase64 = body
+	}
+	return request
+}
+
+func isSecret(name string) bool {
+	var lower = strings.ToLower(name)
+	for _, secret := range secretNames {
+		if strings.Contains(lower, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactQuery(query url.Values) url.Values {
+	for name := range query {
+		if isSecret(name) {
+			query.Del(name)
+		}
+	}
+	return query
+}
+
+// HTTPRequest rebuilds the captured request
+func (r Request) HTTPRequest() (*http.Request, error) {
+	var target = r.Path
+	if r.Query != "" {
+		target += "?" + r.Query
+	}
+	var body = r.Body
+	if r.BodyBase64 != nil {
+		body = string(r.BodyBase64)
+	}
+	var request, err = http.NewRequest(r.Method, target, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header = r.Header.Clone()
+	return request, nil
+}
+
+// Recorder appends captured requests to a rotating JSON Lines file
+type Recorder struct {
+	file *logging.RotatingFile
+}
+
+func NewRecorder(filename string) (*Recorder, error) {
+	var file, err = logging.NewRotatingFile(filename, _MAX_FILE_SIZE_MB, _MAX_BACKUPS, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Record writes the request as one line, so a line is never split across files
+func (r *Recorder) Record(request Request) error {
+	var data, err = json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	_, err = r.file.Write(append(data, '\n'))
+	return err
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+// Read returns the requests captured in a file, in the order they were received
+func Read(filename string) ([]Request, error) {
+	var file, err = os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var requests []Request
+	var scanner = bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), _MAX_LINE_SIZE)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var request Request
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %w", filename, line, err)
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
@@ -0,0 +1,98 @@
+package capture
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRequestLeavesOutSecrets(t *testing.T) {
+	var r = httptest.NewRequest(http.MethodPost, "/plex/webhook?X-Plex-Token=secret&debug=1", nil)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-Emby-Token", "secret")
+	r.Header.Set("X-Api-Key", "secret")
+	r.Header.Set("Cookie", "session=secret")
+
+	var request = NewRequest(r, []byte(`{"event":"media.play"}`), time.Unix(1688159000, 0))
+	assert.Equal(t, "/plex/webhook", request.Path)
+	assert.Equal(t, "debug=1", request.Query)
+	assert.Equal(t, http.Header{"Content-Type": {"application/json"}}, request.Header)
+	assert.Equal(t, `{"event":"media.play"}`, request.Body)
+	assert.Nil(t, request.BodyBase64)
+}
+
+func TestRecordAndRead(t *testing.T) {
+	var filename = filepath.Join(t.TempDir(), "webhooks.jsonl")
+	var recorder, err = NewRecorder(filename)
+	assert.NoError(t, err)
+
+	var start = time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	var text = httptest.NewRequest(http.MethodPost, "/emby/webhook", nil)
+	var binary = httptest.NewRequest(http.MethodPost, "/plex/webhook", nil)
+	assert.NoError(t, recorder.Record(NewRequest(text, []byte(`{"Event":"playback.start"}`), start)))
+	assert.NoError(t, recorder.Record(NewRequest(binary, []byte{0xff, 0xd8, 0xff}, start.Add(time.Minute))))
+	assert.NoError(t, recorder.Close())
+
+	var requests, readErr = Read(filename)
+	assert.NoError(t, readErr)
+	if assert.Len(t, requests, 2) {
+		assert.Equal(t, "/emby/webhook", requests[0].Path)
+		assert.Equal(t, `{"Event":"playback.start"}`, requests[0].Body)
+		assert.True(t, start.Equal(requests[0].Time))
+		assert.Equal(t, []byte{0xff, 0xd8, 0xff}, requests[1].BodyBase64)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var start = time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	var requests = []Request{
+		{Time: start, Method: http.MethodPost, Path: "/emby/webhook", Header: http.Header{}, Body: "first"},
+		{Time: start.Add(10 * time.Minute), Method: http.MethodPost, Path: "/plex/webhook", Header: http.Header{}, BodyBase64: []byte{0xff}},
+		{Time: start.Add(30 * time.Minute), Method: http.MethodPost, Path: "/missing", Header: http.Header{}},
+	}
+
+	var bodies [][]byte
+	var handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body, _ = io.ReadAll(r.Body)
+		bodies = append(bodies, body)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		speed  float64
+		sleeps []time.Duration
+	}{
+		{name: "Original timing", speed: 1, sleeps: []time.Duration{10 * time.Minute, 20 * time.Minute}},
+		{name: "Time compressed", speed: 60, sleeps: []time.Duration{10 * time.Second, 20 * time.Second}},
+		{name: "No waiting", speed: 0, sleeps: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies = nil
+			var sleeps []time.Duration
+			var handled []time.Time
+			var replayer = NewReplayer(handler, tt.speed)
+			replayer.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+			replayer.OnRequest = func(request Request) { handled = append(handled, request.Time) }
+
+			var results, err = replayer.Replay(requests)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sleeps, sleeps)
+			assert.Equal(t, []time.Time{requests[0].Time, requests[1].Time, requests[2].Time}, handled)
+			assert.Equal(t, [][]byte{[]byte("first"), {0xff}, {}}, bodies)
+			if assert.Len(t, results, 3) {
+				assert.Equal(t, http.StatusOK, results[0].Status)
+				assert.Equal(t, http.StatusNotFound, results[2].Status)
+			}
+		})
+	}
+}
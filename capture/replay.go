@@ -0,0 +1,64 @@
+package capture
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// Result is the response to a replayed request
+type Result struct {
+	Request Request
+	Status  int
+	Body    string
+}
+
+// Replayer feeds captured requests to a handler with their original timing
+type Replayer struct {
+	handler http.Handler
+	// Pace relative to the original requests: 1 keeps the original gaps,
+	// 10 replays ten times faster and 0 doesn't wait at all
+	speed float64
+	sleep func(time.Duration)
+
+	// Called before each request is handled, e.g. to set the receipt time
+	OnRequest func(Request)
+}
+
+func NewReplayer(handler http.Handler, speed float64) *Replayer {
+	return &Replayer{
+		handler: handler,
+		speed:   speed,
+		sleep:   time.Sleep,
+	}
+}
+
+// Replay handles the requests in order, waiting between them according to
+// the speed, and returns the responses
+func (r *Replayer) Replay(requests []Request) ([]Result, error) {
+	var results = make([]Result, 0, len(requests))
+	for i, request := range requests {
+		if i > 0 && r.speed > 0 {
+			var gap = request.Time.Sub(requests[i-1].Time)
+			if gap > 0 {
+				r.sleep(time.Duration(float64(gap) / r.speed))
+			}
+		}
+
+		var httpRequest, err = request.HTTPRequest()
+		if err != nil {
+			return results, err
+		}
+		if r.OnRequest != nil {
+			r.OnRequest(request)
+		}
+		var response = httptest.NewRecorder()
+		r.handler.ServeHTTP(response, httpRequest)
+		results = append(results, Result{
+			Request: request,
+			Status:  response.Code,
+			Body:    response.Body.String(),
+		})
+	}
+	return results, nil
+}
@@ -2,14 +2,26 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type letterboxd struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	LogFilms bool   `yaml:"log_films"`
+	Username            string `yaml:"username"`
+	Password            string `yaml:"password"`
+	LogFilms            bool   `yaml:"log_films"`
+	RemoveFromWatchlist bool   `yaml:"remove_from_watchlist"`
+	SyncLikes           bool   `yaml:"sync_likes"`
+	ReverseSync         bool   `yaml:"reverse_sync"`
+	ListTemplate        string `yaml:"list_template"`
+	DiaryTags           string `yaml:"diary_tags"`
+	DiaryReview         string `yaml:"diary_review"`
+
+	// Nil uses the default, zero disables the check
+	DuplicateWindow *time.Duration `yaml:"duplicate_window"`
+	// Nil uses the global setting
+	DryRun *bool `yaml:"dry_run"`
 }
 
 type emby struct {
@@ -19,6 +31,7 @@ type emby struct {
 type plex struct {
 	Username string `yaml:"username"`
 	ID       string `yaml:"id"`
+	Token    string `yaml:"token"`
 }
 
 type user struct {
@@ -27,8 +40,75 @@ type user struct {
 	Plex       plex       `yaml:"plex"`
 }
 
+type embyServer struct {
+	URL          string        `yaml:"url"`
+	ApiKey       string        `yaml:"api_key"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+type plexServer struct {
+	URL          string        `yaml:"url"`
+	Token        string        `yaml:"token"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+type servers struct {
+	Emby embyServer `yaml:"emby"`
+	Plex plexServer `yaml:"plex"`
+}
+
+type reverseSync struct {
+	Interval time.Duration `yaml:"interval"`
+	Lookback time.Duration `yaml:"lookback"`
+}
+
+type notifierTarget struct {
+	Type     string            `yaml:"type"`
+	URL      string            `yaml:"url"`
+	Token    string            `yaml:"token"`
+	Headers  map[string]string `yaml:"headers"`
+	Users    []string          `yaml:"users"`
+	Events   []string          `yaml:"events"`
+	Title    string            `yaml:"title"`
+	Template string            `yaml:"template"`
+}
+
+type dashboard struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type admin struct {
+	Token string `yaml:"token"`
+}
+
+type mqtt struct {
+	Broker          string `yaml:"broker"`
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	ClientID        string `yaml:"client_id"`
+	TopicPrefix     string `yaml:"topic_prefix"`
+	DiscoveryPrefix string `yaml:"discovery_prefix"`
+	// Nil enables Home Assistant discovery
+	Discovery *bool         `yaml:"discovery"`
+	Interval  time.Duration `yaml:"interval"`
+}
+
+type sessions struct {
+	// Nil uses the default, zero keeps sessions until they are logged
+	MaxAge *time.Duration `yaml:"max_age"`
+}
+
 type Config struct {
-	Users []user `yaml:"users"`
+	DryRun      bool             `yaml:"dry_run"`
+	Servers     servers          `yaml:"servers"`
+	ReverseSync reverseSync      `yaml:"reverse_sync"`
+	Notifiers   []notifierTarget `yaml:"notifiers"`
+	Dashboard   dashboard        `yaml:"dashboard"`
+	MQTT        mqtt             `yaml:"mqtt"`
+	Admin       admin            `yaml:"admin"`
+	Sessions    sessions         `yaml:"sessions"`
+	Users       []user           `yaml:"users"`
 }
 
 func Load(filename string) Config {
@@ -0,0 +1,92 @@
+package diarysync
+
+import (
+	"sync"
+	"time"
+
+	"emboxd/letterboxd"
+)
+
+// Max time after marking an item played to ignore the media server's webhook for it
+const EchoWindow time.Duration = 10 * time.Minute
+
+// Guard entries older than this are pruned
+const _GUARD_RETENTION time.Duration = 30 * 24 * time.Hour
+
+// Guard remembers films synced in either direction, so emboxd's own diary
+// entries aren't marked played again and items it marks played aren't logged
+// on Letterboxd a second time
+type Guard struct {
+	lock     sync.Mutex
+	toServer map[string]time.Time // Marked played on a media server by the poller
+	toDiary  map[string]time.Time // Synced to Letterboxd by a worker
+	now      func() time.Time
+}
+
+func NewGuard() *Guard {
+	return &Guard{
+		toServer: make(map[string]time.Time),
+		toDiary:  make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func guardKey(username string, imdbId string) string {
+	return username + "/" + imdbId
+}
+
+func (g *Guard) record(table map[string]time.Time, username string, imdbId string) {
+	if imdbId == "" {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var now = g.now()
+	for key, at := range table {
+		if now.Sub(at) > _GUARD_RETENTION {
+			delete(table, key)
+		}
+	}
+	table[guardKey(username, imdbId)] = now
+}
+
+func (g *Guard) recent(table map[string]time.Time, username string, imdbId string, within time.Duration) bool {
+	if imdbId == "" {
+		return false
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var at, ok = table[guardKey(username, imdbId)]
+	return ok && g.now().Sub(at) <= within
+}
+
+// RecordPlayed remembers that the poller marked a film played for a Letterboxd user
+func (g *Guard) RecordPlayed(username string, imdbId string) {
+	g.record(g.toServer, username, imdbId)
+}
+
+// RecordOutcome remembers a film a worker synced to Letterboxd
+func (g *Guard) RecordOutcome(outcome letterboxd.Outcome) {
+	// Events ignored by the worker didn't go to Letterboxd
+	if outcome.Action == "" && outcome.Skipped != "" || outcome.Err != nil || outcome.DryRun || outcome.Event.Action == letterboxd.FilmLiked || outcome.Event.Action == letterboxd.FilmUnliked {
+		return
+	}
+	g.record(g.toDiary, outcome.Username, outcome.Event.ImdbId)
+}
+
+// IsEcho returns true if a watched event is the media server reporting an
+// item the poller marked played
+func (g *Guard) IsEcho(username string, event letterboxd.Event) bool {
+	if event.Action != letterboxd.FilmWatched && event.Action != letterboxd.FilmLogged {
+		return false
+	}
+	return g.recent(g.toServer, username, event.ImdbId, EchoWindow)
+}
+
+// SyncedByWorker returns true if a worker synced the film to Letterboxd within
+// the given duration, so its diary entry came from emboxd
+func (g *Guard) SyncedByWorker(username string, imdbId string, within time.Duration) bool {
+	return g.recent(g.toDiary, username, imdbId, within)
+}
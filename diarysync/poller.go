@@ -0,0 +1,199 @@
+package diarysync
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"emboxd/letterboxd"
+	"emboxd/mediaserver"
+)
+
+// Default age of diary entries to sync, older entries are ignored
+const DefaultLookback time.Duration = 7 * 24 * time.Hour
+
+// Target is a Letterboxd account and the media server libraries its diary is synced to
+type Target struct {
+	Username  string // Letterboxd username
+	Libraries []mediaserver.Library
+}
+
+// Result describes a diary entry marked played (or failing to be) on a media server
+type Result struct {
+	Username    string
+	Server      string
+	ImdbId      string
+	ItemId      string
+	Title       string
+	WatchedDate time.Time
+	Err         error
+}
+
+// Poller marks films logged on Letterboxd as played on the media servers
+type Poller struct {
+	feed     *letterboxd.DiaryFeed
+	guard    *Guard
+	targets  []Target
+	lookback time.Duration
+	// Called for every item marked played or failing to be
+	OnResult func(Result)
+
+	lock sync.Mutex
+	// Watch dates of diary entries already handled, by username and GUID;
+	// older entries are skipped by their date, so they are forgotten
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+func NewPoller(feed *letterboxd.DiaryFeed, guard *Guard, targets []Target, lookback time.Duration) *Poller {
+	if lookback <= 0 {
+		lookback = DefaultLookback
+	}
+	return &Poller{
+		feed:     feed,
+		guard:    guard,
+		targets:  targets,
+		lookback: lookback,
+		seen:     make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Start polls the diary feeds in the background at the given interval
+func (p *Poller) Start(interval time.Duration) {
+	go func() {
+		for {
+			p.Poll()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Poll syncs new diary entries of every target once
+func (p *Poller) Poll() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for key, watchedDate := range p.seen {
+		if p.now().Sub(watchedDate) > p.lookback {
+			delete(p.seen, key)
+		}
+	}
+	for _, target := range p.targets {
+		p.pollTarget(target)
+	}
+}
+
+func (p *Poller) pollTarget(target Target) {
+	var items, err = p.feed.Recent(target.Username)
+	if err != nil {
+		slog.Warn("Failed to fetch Letterboxd diary feed",
+			slog.String("username", target.Username),
+			slog.String("error", err.Error()))
+		return
+	}
+
+	// Library contents are only fetched once there's a new entry to look up
+	var moviesByServer = make(map[string][]mediaserver.Movie)
+	for _, item := range items {
+		var seenKey = target.Username + "/" + item.Guid
+		if _, seen := p.seen[seenKey]; seen {
+			continue
+		}
+		if p.now().Sub(item.WatchedDate) > p.lookback {
+			continue
+		}
+
+		var imdbId, imdbErr = p.feed.ImdbId(item.Slug)
+		if imdbErr != nil {
+			slog.Warn("Failed to look up IMDb ID of Letterboxd film",
+				slog.String("slug", item.Slug),
+				slog.String("error", imdbErr.Error()))
+			continue
+		}
+
+		if imdbId != "" && p.guard.SyncedByWorker(target.Username, imdbId, p.lookback) {
+			slog.Debug("Diary entry was logged by emboxd, not syncing it back",
+				slog.String("username", target.Username),
+				slog.String("imdbId", imdbId))
+			p.seen[seenKey] = item.WatchedDate
+			continue
+		}
+
+		var complete = true
+		for _, library := range target.Libraries {
+			var movies, loaded = moviesByServer[library.Server()]
+			if !loaded {
+				var moviesErr error
+				movies, moviesErr = library.Movies()
+				if moviesErr != nil {
+					slog.Warn("Failed to list media server movies",
+						slog.String("server", library.Server()),
+						slog.String("username", target.Username),
+						slog.String("error", moviesErr.Error()))
+					complete = false
+					continue
+				}
+				moviesByServer[library.Server()] = movies
+			}
+
+			if !p.markPlayed(target, library, movies, item, imdbId) {
+				complete = false
+			}
+		}
+		if complete {
+			p.seen[seenKey] = item.WatchedDate
+		}
+	}
+}
+
+// markPlayed marks the diary entry's film played in a library unless it
+// already is, returning false if it failed
+func (p *Poller) markPlayed(target Target, library mediaserver.Library, movies []mediaserver.Movie, item letterboxd.DiaryItem, imdbId string) bool {
+	var movie, found = mediaserver.FindMovie(movies, imdbId, item.TmdbId)
+	if !found {
+		slog.Debug("Letterboxd film not in media server library",
+			slog.String("server", library.Server()),
+			slog.String("imdbId", imdbId),
+			slog.String("title", item.Title))
+		return true
+	}
+	if movie.Played {
+		slog.Debug("Media server item already played",
+			slog.String("server", library.Server()),
+			slog.String("imdbId", imdbId),
+			slog.String("itemId", movie.ItemId))
+		return true
+	}
+
+	// Recorded first so the media server's webhook for this can't race it
+	p.guard.RecordPlayed(target.Username, movie.ImdbId)
+	var err = library.MarkPlayed(movie, item.WatchedDate)
+	if err != nil {
+		slog.Error("Failed to mark media server item played",
+			slog.String("server", library.Server()),
+			slog.String("imdbId", imdbId),
+			slog.String("itemId", movie.ItemId),
+			slog.String("error", err.Error()))
+	} else {
+		slog.Info("Marked Letterboxd diary entry played on media server",
+			slog.String("server", library.Server()),
+			slog.String("username", target.Username),
+			slog.String("imdbId", imdbId),
+			slog.String("itemId", movie.ItemId),
+			slog.String("watchedDate", item.WatchedDate.Format(time.DateOnly)))
+	}
+
+	if p.OnResult != nil {
+		p.OnResult(Result{
+			Username:    target.Username,
+			Server:      library.Server(),
+			ImdbId:      imdbId,
+			ItemId:      movie.ItemId,
+			Title:       item.Title,
+			WatchedDate: item.WatchedDate,
+			Err:         err,
+		})
+	}
+	return err == nil
+}
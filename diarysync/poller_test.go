@@ -0,0 +1,159 @@
+package diarysync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"emboxd/letterboxd"
+	"emboxd/mediaserver"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const _TEST_DIARY_FEED = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0" xmlns:letterboxd="https://letterboxd.com" xmlns:tmdb="https://themoviedb.org">
+<channel>
+<item>
+	<title>The Matrix, 1999</title>
+	<link>https://letterboxd.com/john_doe/film/the-matrix/</link>
+	<guid isPermaLink="false">letterboxd-watch-1</guid>
+	<letterboxd:watchedDate>%[1]s</letterboxd:watchedDate>
+	<letterboxd:rewatch>No</letterboxd:rewatch>
+	<letterboxd:filmTitle>The Matrix</letterboxd:filmTitle>
+	<letterboxd:filmYear>1999</letterboxd:filmYear>
+	<tmdb:movieId>603</tmdb:movieId>
+</item>
+<item>
+	<title>Inception, 2010</title>
+	<link>https://letterboxd.com/john_doe/film/inception/</link>
+	<guid isPermaLink="false">letterboxd-watch-2</guid>
+	<letterboxd:watchedDate>%[1]s</letterboxd:watchedDate>
+	<letterboxd:filmTitle>Inception</letterboxd:filmTitle>
+	<letterboxd:filmYear>2010</letterboxd:filmYear>
+	<tmdb:movieId>27205</tmdb:movieId>
+</item>
+<item>
+	<title>Heat, 1995</title>
+	<link>https://letterboxd.com/john_doe/film/heat-1995/</link>
+	<guid isPermaLink="false">letterboxd-watch-3</guid>
+	<letterboxd:watchedDate>2020-01-01</letterboxd:watchedDate>
+	<letterboxd:filmTitle>Heat</letterboxd:filmTitle>
+	<letterboxd:filmYear>1995</letterboxd:filmYear>
+	<tmdb:movieId>949</tmdb:movieId>
+</item>
+</channel>
+</rss>`
+
+// stubServer records the requests made to a stub media server
+type stubServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	requests []string
+}
+
+func newStubServer(t *testing.T, routes map[string]interface{}) *stubServer {
+	var stub = &stubServer{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.lock.Lock()
+		stub.requests = append(stub.requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		stub.lock.Unlock()
+
+		var response, ok = routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if body, isString := response.(string); isString {
+			w.Write([]byte(body))
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *stubServer) made(prefix string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var matches []string
+	for _, request := range s.requests {
+		if len(request) >= len(prefix) && request[:len(prefix)] == prefix {
+			matches = append(matches, request)
+		}
+	}
+	return matches
+}
+
+func TestPoll(t *testing.T) {
+	var today = time.Now().UTC().Format(time.DateOnly)
+
+	var letterboxdStub = newStubServer(t, map[string]interface{}{
+		"GET /john_doe/rss/":    fmt.Sprintf(_TEST_DIARY_FEED, today),
+		"GET /film/the-matrix/": `<a href="http://www.imdb.com/title/tt0133093/maindetails">IMDb</a>`,
+		"GET /film/inception/":  `<a href="http://www.imdb.com/title/tt1375666/maindetails">IMDb</a>`,
+	})
+	var embyStub = newStubServer(t, map[string]interface{}{
+		"GET /Users": []map[string]string{{"Id": "u1", "Name": "john"}},
+		"GET /Users/u1/Items": map[string]interface{}{"Items": []map[string]interface{}{
+			{"Id": "e1", "Name": "The Matrix", "ProviderIds": map[string]string{"Imdb": "tt0133093"}},
+			{"Id": "e2", "Name": "Inception", "ProviderIds": map[string]string{"Imdb": "tt1375666"}},
+		}},
+		"POST /Users/u1/PlayedItems/e1": "",
+	})
+	var plexStub = newStubServer(t, map[string]interface{}{
+		"GET /library/all": map[string]interface{}{"MediaContainer": map[string]interface{}{"Metadata": []map[string]interface{}{
+			{"ratingKey": "42", "title": "The Matrix", "viewCount": 1, "Guid": []map[string]string{{"id": "imdb://tt0133093"}}},
+		}}},
+	})
+
+	var feed = letterboxd.NewDiaryFeed()
+	feed.BaseURL = letterboxdStub.URL
+	var guard = NewGuard()
+	// Inception was logged by emboxd itself and must not be echoed back
+	guard.RecordOutcome(letterboxd.Outcome{
+		Username: "john_doe",
+		Event:    letterboxd.Event{Film: letterboxd.Film{ImdbId: "tt1375666"}, Action: letterboxd.FilmLogged},
+	})
+
+	var poller = NewPoller(feed, guard, []Target{{
+		Username: "john_doe",
+		Libraries: []mediaserver.Library{
+			mediaserver.NewEmby(embyStub.URL, "key").Library("john"),
+			mediaserver.NewPlex(plexStub.URL, "token"),
+		},
+	}}, 0)
+	var results []Result
+	poller.OnResult = func(result Result) {
+		results = append(results, result)
+	}
+
+	poller.Poll()
+	poller.Poll()
+
+	// Only The Matrix is marked played, once, on Emby where it is unplayed
+	var played = embyStub.made("POST /Users/u1/PlayedItems/")
+	assert.Equal(t, []string{"POST /Users/u1/PlayedItems/e1?DatePlayed=" + time.Now().UTC().Format("20060102") + "000000"}, played)
+	assert.Empty(t, plexStub.made("GET /:/scrobble"))
+	assert.Len(t, results, 1)
+	assert.Equal(t, "emby", results[0].Server)
+	assert.Equal(t, "tt0133093", results[0].ImdbId)
+	assert.NoError(t, results[0].Err)
+
+	// The Emby webhook for the item is an echo, not a new watch
+	var echo = letterboxd.Event{Film: letterboxd.Film{ImdbId: "tt0133093"}, Action: letterboxd.FilmWatched}
+	assert.True(t, guard.IsEcho("john_doe", echo))
+	assert.False(t, guard.IsEcho("jane_doe", echo))
+
+	// Entries past the lookback are skipped by their date, so they are forgotten
+	assert.Len(t, poller.seen, 2)
+	poller.now = func() time.Time { return time.Now().Add(DefaultLookback + 48*time.Hour) }
+	poller.Poll()
+	assert.Empty(t, poller.seen)
+	assert.Len(t, embyStub.made("POST /Users/u1/PlayedItems/"), 1)
+}
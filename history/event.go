@@ -3,6 +3,8 @@ package history
 import (
 	"time"
 
+	"emboxd/diarysync"
+	"emboxd/letterboxd"
 	"emboxd/notification"
 )
 
@@ -14,8 +16,14 @@ const (
 	EventTypePlayback EventType = "playback"
 	// EventTypeWatched represents a film being marked as watched
 	EventTypeWatched EventType = "watched"
+	// EventTypeFavorite represents a film being favourited or unfavourited
+	EventTypeFavorite EventType = "favorite"
 	// EventTypeWebhook represents a raw webhook received
 	EventTypeWebhook EventType = "webhook"
+	// EventTypeSync represents a Letterboxd action performed by a worker
+	EventTypeSync EventType = "sync"
+	// EventTypeReverseSync represents a Letterboxd diary entry marked played on a media server
+	EventTypeReverseSync EventType = "reverse_sync"
 )
 
 // Source represents the source of the event
@@ -26,6 +34,12 @@ const (
 	SourceEmby Source = "emby"
 	// SourcePlex represents an event from Plex
 	SourcePlex Source = "plex"
+	// SourceTautulli represents an event from Tautulli, for Plex servers without webhooks
+	SourceTautulli Source = "tautulli"
+	// SourceLetterboxd represents an event from a Letterboxd diary
+	SourceLetterboxd Source = "letterboxd"
+	// SourceManual represents a film logged by hand through the admin API
+	SourceManual Source = "manual"
 )
 
 // Status represents the status of the event processing
@@ -38,11 +52,14 @@ const (
 	StatusError Status = "error"
 	// StatusReceived represents an event that was received but not yet processed
 	StatusReceived Status = "received"
+	// StatusSkipped represents an event that was deliberately not acted on
+	StatusSkipped Status = "skipped"
 )
 
 // Event represents a single event in the history
 type Event struct {
 	ID           string                 `json:"id"`
+	Sequence     uint64                 `json:"sequence"`
 	Timestamp    time.Time              `json:"timestamp"`
 	Type         EventType              `json:"type"`
 	Source       Source                 `json:"source"`
@@ -90,6 +107,11 @@ func FromNotification(notif interface{}, source Source, status Status, processin
 		event.MediaID = n.Metadata.ImdbId
 		event.Details["watched"] = n.Watched
 		event.Details["runtime"] = n.Runtime.String()
+	case notification.FavoriteNotification:
+		event.Type = EventTypeFavorite
+		event.Username = n.Metadata.Username
+		event.MediaID = n.Metadata.ImdbId
+		event.Details["favorite"] = n.Favorite
 	default:
 		event.Type = EventTypeWebhook
 		// For raw webhooks, we don't have structured data
@@ -98,6 +120,74 @@ func FromNotification(notif interface{}, source Source, status Status, processin
 	return event
 }
 
+// WithDecision records what the notification processor did with the event's
+// notification, and why
+func (e *Event) WithDecision(decision notification.Decision) *Event {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details["decision"] = decision
+	return e
+}
+
+// FromOutcome creates an Event from the outcome of a Letterboxd worker action
+func FromOutcome(outcome letterboxd.Outcome) *Event {
+	event := &Event{
+		ID:           GenerateID(),
+		Timestamp:    time.Now(),
+		Type:         EventTypeSync,
+		Source:       Source(outcome.Event.Server),
+		Username:     outcome.Username,
+		MediaID:      outcome.Event.ImdbId,
+		MediaTitle:   outcome.Event.Title,
+		Status:       StatusSuccess,
+		Details:      make(map[string]interface{}, len(outcome.Details)+2),
+		ProcessingMs: int(outcome.Duration.Milliseconds()),
+	}
+
+	if outcome.Err != nil {
+		event.Status = StatusError
+		event.ErrorMessage = outcome.Err.Error()
+	} else if outcome.Skipped != "" {
+		event.Status = StatusSkipped
+		event.Details["skip_reason"] = outcome.Skipped
+	}
+
+	for key, value := range outcome.Details {
+		event.Details[key] = value
+	}
+	event.Details["action"] = outcome.Action
+	event.Details["event_action"] = outcome.Event.Action.String()
+
+	return event
+}
+
+// FromReverseSync creates an Event from a diary entry marked played on a media server
+func FromReverseSync(result diarysync.Result) *Event {
+	event := &Event{
+		ID:         GenerateID(),
+		Timestamp:  time.Now(),
+		Type:       EventTypeReverseSync,
+		Source:     SourceLetterboxd,
+		Username:   result.Username,
+		MediaID:    result.ImdbId,
+		MediaTitle: result.Title,
+		Status:     StatusSuccess,
+		Details: map[string]interface{}{
+			"server":       result.Server,
+			"item_id":      result.ItemId,
+			"watched_date": result.WatchedDate.Format(time.DateOnly),
+		},
+	}
+
+	if result.Err != nil {
+		event.Status = StatusError
+		event.ErrorMessage = result.Err.Error()
+	}
+
+	return event
+}
+
 // GenerateID generates a simple ID for the event
 func GenerateID() string {
 	return time.Now().Format("20060102-150405.000")
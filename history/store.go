@@ -8,11 +8,40 @@ import (
 // DefaultMaxEvents is the default maximum number of events to store
 const DefaultMaxEvents = 100
 
+// DefaultSubscriptionBuffer is the default number of events a subscriber can
+// fall behind before it is dropped
+const DefaultSubscriptionBuffer = 64
+
 // Store is a thread-safe, fixed-size event history store
 type Store struct {
 	sync.RWMutex
 	events *ring.Ring
 	size   int
+
+	sequence      uint64
+	subscriptions map[*Subscription]struct{}
+}
+
+// Subscription receives events as they are added to a store. A subscriber
+// that falls behind by more than its buffer is dropped and its channel closed,
+// so that it can't hold up Add; it can resume with Since.
+type Subscription struct {
+	store  *Store
+	events chan *Event
+}
+
+// Events returns the channel events are delivered on, closed when the
+// subscription ends
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.store.Lock()
+	defer s.store.Unlock()
+
+	s.store.unsubscribe(s)
 }
 
 // NewStore creates a new event history store with the specified size
@@ -22,8 +51,9 @@ func NewStore(size int) *Store {
 	}
 
 	return &Store{
-		events: ring.New(size),
-		size:   size,
+		events:        ring.New(size),
+		size:          size,
+		subscriptions: make(map[*Subscription]struct{}),
 	}
 }
 
@@ -36,22 +66,77 @@ func (s *Store) Add(event *Event) {
 	s.Lock()
 	defer s.Unlock()
 
+	s.sequence++
+	event.Sequence = s.sequence
+
 	// Store the event in the current position and advance
 	s.events.Value = event
 	s.events = s.events.Next()
+
+	for subscription := range s.subscriptions {
+		select {
+		case subscription.events <- event:
+		default:
+			// Too slow to keep up
+			s.unsubscribe(subscription)
+		}
+	}
 }
 
-// GetAll returns all events in the store
-func (s *Store) GetAll() []*Event {
+// Subscribe returns a subscription to events added from now on, buffering up
+// to the given number of events
+func (s *Store) Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	subscription := &Subscription{
+		store:  s,
+		events: make(chan *Event, buffer),
+	}
+	s.subscriptions[subscription] = struct{}{}
+	return subscription
+}
+
+// unsubscribe ends a subscription, the store must be locked
+func (s *Store) unsubscribe(subscription *Subscription) {
+	if _, ok := s.subscriptions[subscription]; ok {
+		delete(s.subscriptions, subscription)
+		close(subscription.events)
+	}
+}
+
+// LastSequence returns the sequence number of the most recently added event
+func (s *Store) LastSequence() uint64 {
 	s.RLock()
 	defer s.RUnlock()
 
-	if s.events.Value == nil {
-		// Empty store
-		return []*Event{}
+	return s.sequence
+}
+
+// Since returns the stored events added after the given sequence number, from
+// oldest to newest
+func (s *Store) Since(sequence uint64) []*Event {
+	events := s.GetAll()
+
+	since := make([]*Event, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Sequence > sequence {
+			since = append(since, events[i])
+		}
 	}
+	return since
+}
+
+// GetAll returns all events in the store
+func (s *Store) GetAll() []*Event {
+	s.RLock()
+	defer s.RUnlock()
 
-	// Count non-nil items
+	// Count non-nil items, the ring is only full once it has wrapped around
 	var count int
 	s.events.Do(func(x interface{}) {
 		if x != nil {
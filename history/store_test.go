@@ -0,0 +1,75 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreDropsSlowSubscribers(t *testing.T) {
+	var store = NewStore(10)
+	var subscription = store.Subscribe(1)
+
+	var done = make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			store.Add(&Event{})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Add blocked on a slow subscriber")
+	}
+
+	var event, ok = <-subscription.Events()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), event.Sequence)
+	_, ok = <-subscription.Events()
+	assert.False(t, ok, "subscription should be closed after falling behind")
+
+	// Closing a dropped subscription is harmless
+	subscription.Close()
+	assert.Len(t, store.Since(3), 2)
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	var store = NewStore(10)
+	var subscription = store.Subscribe(10)
+	store.Add(&Event{})
+
+	subscription.Close()
+	var event, ok = <-subscription.Events()
+	assert.True(t, ok, "events buffered before closing are still delivered")
+	assert.Equal(t, uint64(1), event.Sequence)
+	_, ok = <-subscription.Events()
+	assert.False(t, ok)
+
+	// Adding after the subscription is closed neither delivers nor panics
+	store.Add(&Event{})
+	subscription.Close()
+	assert.Equal(t, uint64(2), store.LastSequence())
+}
+
+func TestStoreSinceAfterWrap(t *testing.T) {
+	var store = NewStore(3)
+	for i := 0; i < 5; i++ {
+		store.Add(&Event{})
+	}
+
+	var sequences = func(events []*Event) []uint64 {
+		var sequences = []uint64{}
+		for _, event := range events {
+			sequences = append(sequences, event.Sequence)
+		}
+		return sequences
+	}
+	// Only the last 3 events are kept, from oldest to newest
+	assert.Equal(t, []uint64{3, 4, 5}, sequences(store.Since(0)))
+	assert.Equal(t, []uint64{3, 4, 5}, sequences(store.Since(1)))
+	assert.Equal(t, []uint64{5}, sequences(store.Since(4)))
+	assert.Equal(t, []uint64{}, sequences(store.Since(5)))
+}
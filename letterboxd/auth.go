@@ -40,6 +40,10 @@ func (u User) isLoggedIn(page ...playwright.Page) bool {
 }
 
 func (u User) Login() error {
+	// Serialize logins so the worker and health prober don't race each other
+	u.loginLock.Lock()
+	defer u.loginLock.Unlock()
+
 	config := DefaultRetryConfig()
 	op := fmt.Sprintf("Login(username=%s)", u.username)
 
@@ -121,3 +125,23 @@ func (u User) Login() error {
 		return nil
 	}, config)
 }
+
+// probe checks that Letterboxd is reachable and the session is authenticated,
+// logging in again if necessary
+func (u User) probe() error {
+	var page = u.newPage("https://letterboxd.com")
+	if page == nil {
+		return &LetterboxdError{
+			Type:          ErrorTypeNetwork,
+			OriginalError: fmt.Errorf("failed to create page - browser not available"),
+			Context:       map[string]interface{}{"username": u.username},
+			Retryable:     true,
+		}
+	}
+	defer page.Close()
+
+	if u.isLoggedIn(page) {
+		return nil
+	}
+	return u.Login()
+}
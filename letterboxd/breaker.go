@@ -0,0 +1,166 @@
+package letterboxd
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Consecutive network/auth failures before the breaker opens
+const _BREAKER_FAILURE_THRESHOLD int = 3
+
+// Backoff before the first probe after the breaker opens
+const _BREAKER_INITIAL_BACKOFF time.Duration = 1 * time.Minute
+
+// Upper bound for the backoff between failed probes
+const _BREAKER_MAX_BACKOFF time.Duration = 30 * time.Minute
+
+// BreakerState represents the state of a worker's circuit breaker
+type BreakerState string
+
+const (
+	// BreakerClosed lets events through normally
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen pauses event consumption until the next probe
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen means a probe is testing whether Letterboxd has recovered
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus is a snapshot of a worker's circuit breaker
+type BreakerStatus struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Trips               int64        `json:"trips"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	NextProbe           *time.Time   `json:"next_probe,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+type circuitBreaker struct {
+	lock           sync.Mutex
+	state          BreakerState
+	failures       int
+	trips          int64
+	openedAt       time.Time
+	nextProbe      time.Time
+	backoff        time.Duration
+	lastError      string
+	threshold      int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	now            func() time.Time
+}
+
+func newCircuitBreaker(threshold int, initialBackoff time.Duration, maxBackoff time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		state:          BreakerClosed,
+		backoff:        initialBackoff,
+		threshold:      threshold,
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		now:            time.Now,
+	}
+}
+
+// countsTowardBreaker returns true if the error indicates Letterboxd itself is
+// unreachable or rejecting the account, rather than a problem with one film
+func countsTowardBreaker(err error) bool {
+	var lbErr *LetterboxdError
+	if !errors.As(err, &lbErr) {
+		return false
+	}
+	switch lbErr.Type {
+	case ErrorTypeNetwork, ErrorTypeAuth, ErrorTypeTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// State returns the current breaker state
+func (b *circuitBreaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// untilProbe returns how long to wait before the next probe is due
+func (b *circuitBreaker) untilProbe() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state != BreakerOpen {
+		return 0
+	}
+	return max(b.nextProbe.Sub(b.now()), 0)
+}
+
+// halfOpen moves an open breaker into the half-open state ahead of a probe
+func (b *circuitBreaker) halfOpen() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == BreakerOpen {
+		b.state = BreakerHalfOpen
+	}
+}
+
+// recordSuccess closes the breaker and resets the backoff
+func (b *circuitBreaker) recordSuccess() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.backoff = b.initialBackoff
+	b.lastError = ""
+}
+
+// recordFailure registers a failure and returns true if the breaker is open afterwards
+func (b *circuitBreaker) recordFailure(err error) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	if err != nil {
+		b.lastError = err.Error()
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		// Probe failed, back off further before the next one
+		b.backoff = min(b.backoff*2, b.maxBackoff)
+		b.open()
+	case BreakerClosed:
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+	return b.state == BreakerOpen
+}
+
+func (b *circuitBreaker) open() {
+	if b.state == BreakerClosed {
+		b.trips++
+		b.openedAt = b.now()
+	}
+	b.state = BreakerOpen
+	b.nextProbe = b.now().Add(b.backoff)
+}
+
+// Status returns a snapshot of the breaker
+func (b *circuitBreaker) Status() BreakerStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var status = BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Trips:               b.trips,
+		LastError:           b.lastError,
+	}
+	if b.state != BreakerClosed {
+		var openedAt, nextProbe = b.openedAt, b.nextProbe
+		status.OpenedAt = &openedAt
+		status.NextProbe = &nextProbe
+	}
+	return status
+}
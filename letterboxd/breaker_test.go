@@ -0,0 +1,75 @@
+package letterboxd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var breaker = newCircuitBreaker(3, time.Minute, 4*time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	var networkErr = &LetterboxdError{Type: ErrorTypeNetwork, OriginalError: fmt.Errorf("connection refused")}
+
+	// Stays closed below the threshold
+	assert.False(t, breaker.recordFailure(networkErr))
+	assert.False(t, breaker.recordFailure(networkErr))
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// Opens once the threshold is crossed
+	assert.True(t, breaker.recordFailure(networkErr))
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.Equal(t, time.Minute, breaker.untilProbe())
+	assert.Equal(t, int64(1), breaker.Status().Trips)
+
+	// Failed probe doubles the backoff
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), breaker.untilProbe())
+	breaker.halfOpen()
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.True(t, breaker.recordFailure(networkErr))
+	assert.Equal(t, 2*time.Minute, breaker.untilProbe())
+
+	// Backoff is capped
+	for range 3 {
+		now = now.Add(breaker.untilProbe())
+		breaker.halfOpen()
+		breaker.recordFailure(networkErr)
+	}
+	assert.Equal(t, 4*time.Minute, breaker.untilProbe())
+	assert.Equal(t, int64(1), breaker.Status().Trips)
+
+	// Successful probe closes the breaker and resets the backoff
+	breaker.halfOpen()
+	breaker.recordSuccess()
+	var status = breaker.Status()
+	assert.Equal(t, BreakerClosed, status.State)
+	assert.Equal(t, 0, status.ConsecutiveFailures)
+	assert.Nil(t, status.NextProbe)
+	assert.Equal(t, time.Minute, breaker.backoff)
+}
+
+func TestCountsTowardBreaker(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Network error", &LetterboxdError{Type: ErrorTypeNetwork}, true},
+		{"Auth error", &LetterboxdError{Type: ErrorTypeAuth}, true},
+		{"Timeout error", &LetterboxdError{Type: ErrorTypeTimeout}, true},
+		{"UI error", &LetterboxdError{Type: ErrorTypeUI}, false},
+		{"Wrapped network error", fmt.Errorf("login: %w", &LetterboxdError{Type: ErrorTypeNetwork}), true},
+		{"Plain error", fmt.Errorf("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, countsTowardBreaker(tt.err))
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package letterboxd
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Activity is when a worker last changed Letterboxd, and last failed to
+type Activity struct {
+	LastSuccess time.Time `json:"last_success"`
+	LastError   time.Time `json:"last_error"`
+	// Error of the most recent failure
+	Error string `json:"error,omitempty"`
+}
+
+// workerControl holds the state changed from outside the worker's goroutine
+type workerControl struct {
+	lock     sync.Mutex
+	paused   bool
+	resumed  chan struct{} // Closed when a paused worker is resumed
+	activity Activity
+	// Held while the worker uses its Letterboxd session, so a relogin from
+	// the admin API waits for the current event instead of cutting into it
+	session sync.Mutex
+}
+
+// Pause stops the worker taking new events until it is resumed; events keep
+// being queued in the meantime, without holding up the media servers' webhooks
+// and pollers
+func (w *Worker) Pause() {
+	w.control.lock.Lock()
+	defer w.control.lock.Unlock()
+
+	if !w.control.paused {
+		w.control.paused = true
+		w.control.resumed = make(chan struct{})
+		slog.Info("Paused Letterboxd worker", slog.String("username", w.user.username))
+	}
+}
+
+// Resume carries on with queued events after Pause
+func (w *Worker) Resume() {
+	w.control.lock.Lock()
+	defer w.control.lock.Unlock()
+
+	if w.control.paused {
+		w.control.paused = false
+		close(w.control.resumed)
+		slog.Info("Resumed Letterboxd worker", slog.String("username", w.user.username))
+	}
+}
+
+// Paused returns true if the worker has been paused
+func (w *Worker) Paused() bool {
+	w.control.lock.Lock()
+	defer w.control.lock.Unlock()
+
+	return w.control.paused
+}
+
+// awaitResume blocks while the worker is paused
+func (w *Worker) awaitResume() {
+	w.control.lock.Lock()
+	var paused, resumed = w.control.paused, w.control.resumed
+	w.control.lock.Unlock()
+
+	if paused {
+		<-resumed
+	}
+}
+
+// Relogin drops the worker's Letterboxd session and logs in again, e.g. after
+// the password was changed or the session was signed out elsewhere. It waits
+// for the event being processed, if any. A successful login closes the
+// circuit breaker, so queued events carry on.
+func (w *Worker) Relogin() error {
+	w.control.session.Lock()
+	defer w.control.session.Unlock()
+
+	slog.Info("Logging in to Letterboxd again", slog.String("username", w.user.username))
+	if err := w.user.context.ClearCookies(); err != nil {
+		return err
+	}
+	if err := w.user.Login(); err != nil {
+		return err
+	}
+
+	w.breaker.recordSuccess()
+	w.recordStatus(Status{Username: w.user.username, IsConnected: true, LastChecked: time.Now()})
+	return nil
+}
+
+// Activity returns when the worker last succeeded and failed to process an
+// event
+func (w *Worker) Activity() Activity {
+	w.control.lock.Lock()
+	defer w.control.lock.Unlock()
+
+	return w.control.activity
+}
+
+// recordActivity updates the worker's activity with an event's outcome
+func (w *Worker) recordActivity(outcome Outcome) {
+	w.control.lock.Lock()
+	defer w.control.lock.Unlock()
+
+	if outcome.Err != nil {
+		w.control.activity.LastError = time.Now()
+		w.control.activity.Error = outcome.Err.Error()
+	} else if outcome.Skipped == "" {
+		w.control.activity.LastSuccess = time.Now()
+	}
+}
@@ -0,0 +1,106 @@
+package letterboxd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPauseResume(t *testing.T) {
+	var worker = Worker{user: User{username: "john_doe"}, control: &workerControl{}}
+	assert.False(t, worker.Paused())
+
+	worker.Pause()
+	worker.Pause()
+	assert.True(t, worker.Paused())
+
+	var done = make(chan struct{})
+	go func() {
+		worker.awaitResume()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("awaitResume returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	worker.Resume()
+	worker.Resume()
+	assert.False(t, worker.Paused())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("awaitResume blocked after resuming")
+	}
+}
+
+func TestWorkerQueuesWhilePaused(t *testing.T) {
+	var events = newEventQueue()
+	var worker = Worker{
+		debouncer: newDebouncer(events),
+		user:      User{username: "john_doe"},
+		events:    events,
+		control:   &workerControl{},
+	}
+	worker.Pause()
+
+	var done = make(chan struct{})
+	go func() {
+		for i := range 50 {
+			worker.HandleEvent(Event{Film: Film{ImdbId: fmt.Sprintf("tt%07d", i)}, Action: FilmWatched})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("HandleEvent blocked while the worker was paused")
+	}
+	assert.Equal(t, 50, worker.Backlog())
+}
+
+func TestWorkerActivity(t *testing.T) {
+	var worker = Worker{user: User{username: "john_doe"}, control: &workerControl{}}
+
+	worker.recordActivity(Outcome{Skipped: "already logged"})
+	assert.Equal(t, Activity{}, worker.Activity())
+
+	worker.recordActivity(Outcome{})
+	var activity = worker.Activity()
+	assert.False(t, activity.LastSuccess.IsZero())
+	assert.True(t, activity.LastError.IsZero())
+
+	worker.recordActivity(Outcome{Err: errors.New("film page not found")})
+	activity = worker.Activity()
+	assert.False(t, activity.LastError.IsZero())
+	assert.Equal(t, "film page not found", activity.Error)
+}
+
+func TestRecordStatus(t *testing.T) {
+	var changes []Status
+	var worker = Worker{
+		user:    User{username: "john_doe"},
+		status:  &statusCache{},
+		breaker: newCircuitBreaker(1, time.Hour, time.Hour),
+		options: WorkerOptions{OnStatusChange: func(status Status) {
+			changes = append(changes, status)
+		}},
+	}
+
+	// The first status is only reported when logged out
+	worker.recordStatus(Status{IsConnected: true, LastChecked: time.Now()})
+	assert.Empty(t, changes)
+	worker.recordStatus(Status{IsConnected: true, LastChecked: time.Now()})
+	assert.Empty(t, changes)
+	worker.recordStatus(Status{IsConnected: false, LastChecked: time.Now()})
+	worker.recordStatus(Status{IsConnected: true, LastChecked: time.Now()})
+	if assert.Len(t, changes, 2) {
+		assert.False(t, changes[0].IsConnected)
+		assert.True(t, changes[1].IsConnected)
+	}
+	assert.True(t, worker.Status().IsConnected)
+}
@@ -13,15 +13,15 @@ type debouncer struct {
 	removableElementByImdbId map[string]*list.Element
 	loggedImdbIds            map[string]bool
 	lock                     sync.Mutex
-	channel                  chan Event
+	events                   *eventQueue
 }
 
-func newDebouncer(channel chan Event) debouncer {
+func newDebouncer(events *eventQueue) debouncer {
 	return debouncer{
 		queue:                    list.New(),
 		removableElementByImdbId: make(map[string]*list.Element),
 		loggedImdbIds:            make(map[string]bool),
-		channel:                  channel,
+		events:                   events,
 	}
 }
 
@@ -38,5 +38,5 @@ func (d *debouncer) debounce(event Event) {
 	}
 
 	// TODO: fix
-	d.channel <- event
+	d.events.push(event)
 }
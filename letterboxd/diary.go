@@ -0,0 +1,171 @@
+package letterboxd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const _LETTERBOXD_URL string = "https://letterboxd.com"
+
+const _DIARY_FEED_TIMEOUT time.Duration = 30 * time.Second
+
+// Film page links within a diary entry, e.g. /john_doe/film/the-matrix/1/
+var diaryFilmSlugPattern = regexp.MustCompile(`/film/([a-z0-9-]+)/`)
+
+// IMDb link on a Letterboxd film page
+var filmImdbPattern = regexp.MustCompile(`imdb\.com/title/(tt\d+)`)
+
+// DiaryItem is a diary entry from a user's Letterboxd RSS feed
+type DiaryItem struct {
+	Guid        string
+	Slug        string
+	Title       string
+	Year        int
+	TmdbId      string
+	WatchedDate time.Time
+	Published   time.Time
+	Rewatch     bool
+}
+
+type rssFeed struct {
+	Items []struct {
+		Link        string `xml:"link"`
+		Guid        string `xml:"guid"`
+		PubDate     string `xml:"pubDate"`
+		WatchedDate string `xml:"https://letterboxd.com watchedDate"`
+		Rewatch     string `xml:"https://letterboxd.com rewatch"`
+		FilmTitle   string `xml:"https://letterboxd.com filmTitle"`
+		FilmYear    string `xml:"https://letterboxd.com filmYear"`
+		TmdbId      string `xml:"https://themoviedb.org movieId"`
+	} `xml:"channel>item"`
+}
+
+// DiaryFeed reads diary entries from Letterboxd's public RSS feeds, which
+// don't need the browser or a login
+type DiaryFeed struct {
+	BaseURL string
+	client  *http.Client
+
+	lock    sync.Mutex
+	imdbIds map[string]string // Cached IMDb ID by film slug
+}
+
+func NewDiaryFeed() *DiaryFeed {
+	return &DiaryFeed{
+		BaseURL: _LETTERBOXD_URL,
+		client:  &http.Client{Timeout: _DIARY_FEED_TIMEOUT},
+		imdbIds: make(map[string]string),
+	}
+}
+
+func (f *DiaryFeed) get(path string) ([]byte, error) {
+	var url = strings.TrimSuffix(f.BaseURL, "/") + path
+	var request, err = http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", "emboxd")
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, &LetterboxdError{
+			Type:          ErrorTypeNetwork,
+			OriginalError: err,
+			Context:       map[string]interface{}{"url": url},
+			Retryable:     true,
+		}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, &LetterboxdError{
+			Type:          ErrorTypeNotFound,
+			OriginalError: fmt.Errorf("%s not found", url),
+			Context:       map[string]interface{}{"url": url},
+			Retryable:     false,
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, &LetterboxdError{
+			Type:          ErrorTypeNetwork,
+			OriginalError: fmt.Errorf("unexpected status %d from %s", response.StatusCode, url),
+			Context:       map[string]interface{}{"url": url, "status": response.StatusCode},
+			Retryable:     true,
+		}
+	}
+	return io.ReadAll(response.Body)
+}
+
+// Recent returns the diary entries in the user's RSS feed, newest first.
+// Reviews without a watched date and lists are left out.
+func (f *DiaryFeed) Recent(username string) ([]DiaryItem, error) {
+	var data, err = f.get(fmt.Sprintf("/%s/rss/", username))
+	if err != nil {
+		return nil, err
+	}
+	return parseDiaryFeed(data)
+}
+
+func parseDiaryFeed(data []byte) ([]DiaryItem, error) {
+	var feed rssFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse diary feed: %w", err)
+	}
+
+	var items = make([]DiaryItem, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		var watchedDate, dateErr = time.Parse(time.DateOnly, item.WatchedDate)
+		if dateErr != nil {
+			continue
+		}
+		var match = diaryFilmSlugPattern.FindStringSubmatch(item.Link)
+		if match == nil {
+			continue
+		}
+
+		var year, _ = strconv.Atoi(item.FilmYear)
+		var published, _ = time.Parse(time.RFC1123Z, item.PubDate)
+		items = append(items, DiaryItem{
+			Guid:        item.Guid,
+			Slug:        match[1],
+			Title:       item.FilmTitle,
+			Year:        year,
+			TmdbId:      item.TmdbId,
+			WatchedDate: watchedDate,
+			Published:   published,
+			Rewatch:     item.Rewatch == "Yes",
+		})
+	}
+	return items, nil
+}
+
+// ImdbId looks up the IMDb ID linked from a Letterboxd film page, returning
+// an empty string if the page has none
+func (f *DiaryFeed) ImdbId(slug string) (string, error) {
+	f.lock.Lock()
+	var imdbId, cached = f.imdbIds[slug]
+	f.lock.Unlock()
+	if cached {
+		return imdbId, nil
+	}
+
+	var data, err = f.get(fmt.Sprintf("/film/%s/", slug))
+	if err != nil {
+		return "", err
+	}
+	if match := filmImdbPattern.FindSubmatch(data); match != nil {
+		imdbId = string(match[1])
+	}
+
+	f.lock.Lock()
+	f.imdbIds[slug] = imdbId
+	f.lock.Unlock()
+	return imdbId, nil
+}
@@ -0,0 +1,76 @@
+package letterboxd
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Skip reason for films that already have a diary entry, e.g. logged by hand
+const SkipAlreadyLogged string = "already_logged"
+
+// Default for WorkerOptions.DuplicateWindow, i.e. the same or an adjacent day
+const DefaultDuplicateWindow time.Duration = 24 * time.Hour
+
+// findLoggedEntry returns a diary entry for the film watched within the window
+// of the given time. IMDb IDs are looked up through imdbIdOf, since the feed
+// only links Letterboxd slugs and TMDb IDs.
+func findLoggedEntry(items []DiaryItem, film Film, at time.Time, window time.Duration, imdbIdOf func(string) (string, error)) (DiaryItem, bool) {
+	var watchedDate = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	for _, item := range items {
+		var difference = watchedDate.Sub(item.WatchedDate)
+		if difference > window || difference < -window {
+			continue
+		}
+
+		switch {
+		case film.Slug != "" && item.Slug == film.Slug:
+			return item, true
+		case film.TmdbId != "" && item.TmdbId == film.TmdbId:
+			return item, true
+		case film.ImdbId != "":
+			var imdbId, err = imdbIdOf(item.Slug)
+			if err != nil {
+				slog.Debug("Failed to look up IMDb ID of diary entry", slog.String("slug", item.Slug), slog.String("error", err.Error()))
+				continue
+			}
+			if imdbId == film.ImdbId {
+				return item, true
+			}
+		}
+	}
+	return DiaryItem{}, false
+}
+
+// findManualLog checks the user's recent diary for an entry of the event's
+// film, returning the skip reason if logging it again would duplicate it.
+// The check is best effort and never blocks logging.
+func (w *Worker) findManualLog(event Event, details map[string]interface{}) string {
+	if w.options.DuplicateWindow <= 0 {
+		return ""
+	}
+	if strings.Contains(w.user.username, "@") {
+		slog.Debug("Diary feed needs a Letterboxd username rather than an email, not checking for duplicates")
+		return ""
+	}
+
+	var items, err = w.diary.Recent(w.user.username)
+	if err != nil {
+		slog.Warn("Failed to fetch diary to check for duplicates, logging anyway",
+			slog.String("username", w.user.username),
+			slog.String("error", err.Error()))
+		return ""
+	}
+
+	var entry, found = findLoggedEntry(items, event.Film, event.Time, w.options.DuplicateWindow, w.diary.ImdbId)
+	if !found {
+		return ""
+	}
+
+	slog.Info("Film already has a diary entry, not logging it again",
+		slog.String("imdbId", event.ImdbId),
+		slog.String("watchedDate", entry.WatchedDate.Format(time.DateOnly)))
+	details["diary_entry"] = entry.Guid
+	details["diary_watched_date"] = entry.WatchedDate.Format(time.DateOnly)
+	return SkipAlreadyLogged
+}
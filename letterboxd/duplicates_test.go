@@ -0,0 +1,42 @@
+package letterboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindLoggedEntry(t *testing.T) {
+	var items = []DiaryItem{
+		{Guid: "letterboxd-watch-1", Slug: "the-matrix", TmdbId: "603", WatchedDate: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{Guid: "letterboxd-watch-2", Slug: "inception", TmdbId: "27205", WatchedDate: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	var imdbIds = map[string]string{"the-matrix": "tt0133093", "inception": "tt1375666"}
+	var imdbIdOf = func(slug string) (string, error) {
+		return imdbIds[slug], nil
+	}
+	var watched = time.Date(2026, 10, 15, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		film     Film
+		at       time.Time
+		expected string
+	}{
+		{"Same day by IMDb ID", Film{ImdbId: "tt0133093"}, watched, "letterboxd-watch-1"},
+		{"Next day by TMDb ID", Film{TmdbId: "603"}, watched.AddDate(0, 0, 1), "letterboxd-watch-1"},
+		{"Mapped slug", Film{Slug: "the-matrix"}, watched, "letterboxd-watch-1"},
+		{"Outside window", Film{ImdbId: "tt0133093"}, watched.AddDate(0, 0, 2), ""},
+		{"Older entry outside window", Film{ImdbId: "tt1375666"}, watched, ""},
+		{"Other film", Film{ImdbId: "tt0000001"}, watched, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entry, found = findLoggedEntry(items, tt.film, tt.at, DefaultDuplicateWindow, imdbIdOf)
+			assert.Equal(t, tt.expected != "", found)
+			assert.Equal(t, tt.expected, entry.Guid)
+		})
+	}
+}
@@ -19,6 +19,10 @@ const (
 	ErrorTypeUI ErrorType = "ui"
 	// ErrorTypeTimeout represents timeout errors
 	ErrorTypeTimeout ErrorType = "timeout"
+	// ErrorTypeNotFound represents a film page that does not exist on Letterboxd
+	ErrorTypeNotFound ErrorType = "not_found"
+	// ErrorTypeMismatch represents a film page that doesn't match the media server's metadata
+	ErrorTypeMismatch ErrorType = "mismatch"
 	// ErrorTypeUnknown represents unknown errors
 	ErrorTypeUnknown ErrorType = "unknown"
 )
@@ -53,6 +57,12 @@ func IsNetworkError(err error) bool {
 	return errors.As(err, &lbErr) && lbErr.Type == ErrorTypeNetwork
 }
 
+// IsVerificationError returns true if the film page was missing or didn't match
+func IsVerificationError(err error) bool {
+	var lbErr *LetterboxdError
+	return errors.As(err, &lbErr) && (lbErr.Type == ErrorTypeNotFound || lbErr.Type == ErrorTypeMismatch)
+}
+
 // IsRetryable returns true if the error is retryable
 func IsRetryable(err error) bool {
 	var lbErr *LetterboxdError
@@ -82,6 +92,8 @@ func DefaultRetryConfig() RetryConfig {
 		},
 		NonRetryableErrs: []ErrorType{
 			ErrorTypeAuth,
+			ErrorTypeNotFound,
+			ErrorTypeMismatch,
 		},
 	}
 }
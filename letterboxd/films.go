@@ -7,52 +7,146 @@ import (
 	"strings"
 	"time"
 
+	"emboxd/mapping"
+
 	"github.com/playwright-community/playwright-go"
 )
 
-func (u User) SetFilmWatched(imdbId string, watched bool) error {
-	config := DefaultRetryConfig()
-	op := fmt.Sprintf("SetFilmWatched(imdbId=%s, watched=%t)", imdbId, watched)
+// Film identifies a film on the media server, used to find and verify its Letterboxd page
+type Film struct {
+	ImdbId string
+	TmdbId string
+	Server string // Media server the film was played on ("emby" or "plex"), or "manual"
+	ItemId string // Emby item ID or Plex rating key
+	Title  string
+	Year   int
+	Slug   string // Letterboxd slug from a manual mapping, used instead of the IMDb redirect
+}
 
-	return WithRetry(op, func() error {
-		var url = fmt.Sprintf("https://letterboxd.com/imdb/%s", imdbId)
-		var page = u.newPage(url)
-		defer page.Close()
+// url returns the Letterboxd page for the film
+func (f Film) url() string {
+	if f.Slug != "" {
+		return fmt.Sprintf("https://letterboxd.com/film/%s/", f.Slug)
+	}
+	return fmt.Sprintf("https://letterboxd.com/imdb/%s", f.ImdbId)
+}
 
-		// Reauthenticate if necessary
-		if !u.isLoggedIn(page) {
-			slog.Warn("Not logged in, authenticating...")
+// mappingIds returns the identifiers used to look up a manual mapping for the film
+func (f Film) mappingIds() mapping.Ids {
+	return mapping.Ids{
+		Imdb:   f.ImdbId,
+		Tmdb:   f.TmdbId,
+		Server: f.Server,
+		Item:   f.ItemId,
+	}
+}
 
-			loginErr := u.Login()
-			if loginErr != nil {
-				return &LetterboxdError{
-					Type:          ErrorTypeAuth,
-					OriginalError: loginErr,
-					Context:       map[string]interface{}{"imdbId": imdbId},
-					Retryable:     false,
-				}
+// id returns the most specific identifier available for logging
+func (f Film) id() string {
+	switch {
+	case f.ImdbId != "":
+		return f.ImdbId
+	case f.Slug != "":
+		return f.Slug
+	case f.TmdbId != "":
+		return "tmdb:" + f.TmdbId
+	default:
+		return f.Server + ":" + f.ItemId
+	}
+}
+
+// openFilmPage loads the film's Letterboxd page, logging in again if the
+// session has expired, and verifies it is the expected film. The caller must
+// close the returned page.
+func (u User) openFilmPage(film Film) (playwright.Page, error) {
+	var imdbId = film.id()
+	var url = film.url()
+	var page = u.newPage(url)
+	if page == nil {
+		slog.Error("Failed to create page for Letterboxd", slog.String("imdbId", imdbId), slog.String("url", url))
+		return nil, &LetterboxdError{
+			Type:          ErrorTypeNetwork,
+			OriginalError: fmt.Errorf("failed to create page"),
+			Context:       map[string]interface{}{"url": url, "imdbId": imdbId},
+			Retryable:     true,
+		}
+	}
+
+	// Reauthenticate if necessary
+	if !u.isLoggedIn(page) {
+		slog.Warn("Not logged in, authenticating...")
+
+		loginErr := u.Login()
+		if loginErr != nil {
+			slog.Error("Failed to login", slog.String("imdbId", imdbId), slog.String("error", loginErr.Error()))
+			page.Close()
+			return nil, &LetterboxdError{
+				Type:          ErrorTypeAuth,
+				OriginalError: loginErr,
+				Context:       map[string]interface{}{"imdbId": imdbId},
+				Retryable:     false,
 			}
+		}
 
-			if _, err := page.Reload(); err != nil {
-				return &LetterboxdError{
-					Type:          ErrorTypeNetwork,
-					OriginalError: err,
-					Context:       map[string]interface{}{"url": url, "imdbId": imdbId},
-					Retryable:     true,
-				}
+		if _, err := page.Reload(); err != nil {
+			slog.Error("Failed to reload page after login", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
+			page.Close()
+			return nil, &LetterboxdError{
+				Type:          ErrorTypeNetwork,
+				OriginalError: err,
+				Context:       map[string]interface{}{"url": url, "imdbId": imdbId},
+				Retryable:     true,
 			}
 		}
+	}
 
-		// Allow watched information to populate
-		time.Sleep(3 * time.Second)
+	// Allow page information to populate
+	time.Sleep(3 * time.Second)
+
+	// Verify we're on the correct page
+	pageTitle, _ := page.Title()
+	pageURL := page.URL()
+	slog.Info("Letterboxd page loaded",
+		slog.String("imdbId", imdbId),
+		slog.String("pageTitle", pageTitle),
+		slog.String("pageURL", pageURL))
+	if err := u.verifyFilmPage(page, film); err != nil {
+		page.Close()
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// findVisible returns the first visible locator among the selectors, for
+// elements whose markup varies between Letterboxd page layouts
+func findVisible(page playwright.Page, selectors []string) (playwright.Locator, bool) {
+	for _, selector := range selectors {
+		var locator = page.Locator(selector).First()
+		if visible, _ := locator.IsVisible(); visible {
+			slog.Debug("Found element", slog.String("selector", selector))
+			return locator, true
+		}
+	}
+	return nil, false
+}
+
+// containsClass returns true if a class attribute value includes the class
+func containsClass(classes string, class string) bool {
+	return slices.Contains(strings.Fields(classes), class)
+}
+
+func (u User) SetFilmWatched(film Film, watched bool) error {
+	var imdbId = film.id()
+	config := DefaultRetryConfig()
+	op := fmt.Sprintf("SetFilmWatched(imdbId=%s, watched=%t)", imdbId, watched)
 
-		// Verify we're on the correct page
-		pageTitle, _ := page.Title()
-		pageURL := page.URL()
-		slog.Info("Letterboxd page loaded", 
-			slog.String("imdbId", imdbId),
-			slog.String("pageTitle", pageTitle),
-			slog.String("pageURL", pageURL))
+	return WithRetry(op, func() error {
+		var page, pageErr = u.openFilmPage(film)
+		if pageErr != nil {
+			return pageErr
+		}
+		defer page.Close()
 
 		// Find the watched button
 		slog.Debug("Looking for watched button", slog.String("imdbId", imdbId), slog.String("selector", "span.action-large.-watch .action.-watch"))
@@ -95,65 +189,53 @@ func (u User) SetFilmWatched(imdbId string, watched bool) error {
 	}, config)
 }
 
-func (u User) LogFilmWatched(imdbId string, date ...time.Time) error {
-	if len(date) == 0 {
-		date = append(date, time.Now())
+// DiaryEntry holds the optional fields of a Letterboxd diary entry
+type DiaryEntry struct {
+	Date   time.Time // Defaults to now
+	Tags   []string
+	Review string
+	Rating float64 // Stars from 0.5 to 5, zero for none
+}
+
+// fillTags enters each tag into the diary form's tag editor
+func fillTags(page playwright.Page, tags []string) error {
+	var tagInput, found = findVisible(page, []string{
+		"#diary-entry-form-modal .tag-editor input",
+		"#diary-entry-form-modal input#frm-tags",
+		"#diary-entry-form-modal input[name='tags']",
+	})
+	if !found {
+		return fmt.Errorf("failed to find tags input")
+	}
+
+	for _, tag := range tags {
+		if err := tagInput.Fill(tag); err != nil {
+			return err
+		}
+		if err := tagInput.Press("Enter"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u User) LogFilmWatched(film Film, entry DiaryEntry) error {
+	var imdbId = film.id()
+	if entry.Date.IsZero() {
+		entry.Date = time.Now()
 	}
+	var date = entry.Date.Format(time.DateOnly)
 
 	config := DefaultRetryConfig()
-	op := fmt.Sprintf("LogFilmWatched(imdbId=%s, date=%s)", imdbId, date[0].Format(time.DateOnly))
+	op := fmt.Sprintf("LogFilmWatched(imdbId=%s, date=%s)", imdbId, date)
 
 	return WithRetry(op, func() error {
-		var url = fmt.Sprintf("https://letterboxd.com/imdb/%s", imdbId)
-		var page = u.newPage(url)
-		if page == nil {
-			slog.Error("Failed to create page for Letterboxd", slog.String("imdbId", imdbId), slog.String("url", url))
-			return &LetterboxdError{
-				Type:          ErrorTypeNetwork,
-				OriginalError: fmt.Errorf("failed to create page"),
-				Context:       map[string]interface{}{"url": url, "imdbId": imdbId},
-				Retryable:     true,
-			}
+		var page, pageErr = u.openFilmPage(film)
+		if pageErr != nil {
+			return pageErr
 		}
 		defer page.Close()
 
-		// Verify we're on the correct page
-		pageTitle, _ := page.Title()
-		pageURL := page.URL()
-		slog.Info("Letterboxd page loaded for logging", 
-			slog.String("imdbId", imdbId),
-			slog.String("pageTitle", pageTitle),
-			slog.String("pageURL", pageURL))
-
-		// Reauthenticate if necessary
-		if !u.isLoggedIn(page) {
-			slog.Warn("Not logged in, authenticating...")
-
-			loginErr := u.Login()
-			if loginErr != nil {
-				slog.Error("Failed to login", slog.String("imdbId", imdbId), slog.String("error", loginErr.Error()))
-				return &LetterboxdError{
-					Type:          ErrorTypeAuth,
-					OriginalError: loginErr,
-					Context:       map[string]interface{}{"imdbId": imdbId},
-					Retryable:     false,
-				}
-			}
-			
-			if _, err := page.Reload(); err != nil {
-				slog.Error("Failed to reload page after login", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
-				return &LetterboxdError{
-					Type:          ErrorTypeNetwork,
-					OriginalError: err,
-					Context:       map[string]interface{}{"url": url, "imdbId": imdbId},
-					Retryable:     true,
-				}
-			}
-		}
-
-		// Allow page to fully load
-		time.Sleep(3 * time.Second)
-
 		// Click the 'Review or log...' button
 		slog.Info("Attempting to log film on Letterboxd", slog.String("imdbId", imdbId))
 		slog.Debug("Looking for 'Review or log...' button", slog.String("imdbId", imdbId))
@@ -230,8 +312,8 @@ func (u User) LogFilmWatched(imdbId string, date ...time.Time) error {
 		}
 
 		// Fill form and save log entry
-		slog.Debug("Setting date in diary form", slog.String("imdbId", imdbId), slog.String("date", date[0].Format(time.DateOnly)))
-		var javascriptSetDate = fmt.Sprintf("document.querySelector('input#frm-viewing-date-string').value = '%s'", date[0].Format(time.DateOnly))
+		slog.Debug("Setting date in diary form", slog.String("imdbId", imdbId), slog.String("date", date))
+		var javascriptSetDate = fmt.Sprintf("document.querySelector('input#frm-viewing-date-string').value = '%s'", date)
 		if _, err := page.Evaluate(javascriptSetDate, nil); err != nil {
 			slog.Error("Failed to set date", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
 			return &LetterboxdError{
@@ -242,6 +324,47 @@ func (u User) LogFilmWatched(imdbId string, date ...time.Time) error {
 			}
 		}
 		
+		if entry.Rating > 0 {
+			// The star widget stores half stars in a hidden input, 1 to 10
+			slog.Debug("Setting rating in diary form", slog.String("imdbId", imdbId), slog.Float64("rating", entry.Rating))
+			var javascriptSetRating = fmt.Sprintf("document.querySelector('#diary-entry-form-modal input[name=rating]').value = '%d'", int(entry.Rating*2))
+			if _, err := page.Evaluate(javascriptSetRating, nil); err != nil {
+				slog.Error("Failed to set rating", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
+				return &LetterboxdError{
+					Type:          ErrorTypeUI,
+					OriginalError: err,
+					Context:       map[string]interface{}{"imdbId": imdbId, "action": "set rating"},
+					Retryable:     true,
+				}
+			}
+		}
+
+		if entry.Review != "" {
+			slog.Debug("Setting review in diary form", slog.String("imdbId", imdbId))
+			if err := page.Locator("#diary-entry-form-modal textarea[name='review']").Fill(entry.Review); err != nil {
+				slog.Error("Failed to set review", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
+				return &LetterboxdError{
+					Type:          ErrorTypeUI,
+					OriginalError: err,
+					Context:       map[string]interface{}{"imdbId": imdbId, "action": "set review"},
+					Retryable:     true,
+				}
+			}
+		}
+
+		if len(entry.Tags) > 0 {
+			slog.Debug("Setting tags in diary form", slog.String("imdbId", imdbId), slog.String("tags", strings.Join(entry.Tags, ",")))
+			if err := fillTags(page, entry.Tags); err != nil {
+				slog.Error("Failed to set tags", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
+				return &LetterboxdError{
+					Type:          ErrorTypeUI,
+					OriginalError: err,
+					Context:       map[string]interface{}{"imdbId": imdbId, "action": "set tags"},
+					Retryable:     true,
+				}
+			}
+		}
+
 		// Make sure the watched checkbox is checked
 		var watchedCheckbox = page.Locator("input[name='watched']")
 		if watchedCheckbox != nil {
@@ -266,7 +389,7 @@ func (u User) LogFilmWatched(imdbId string, date ...time.Time) error {
 			}
 		}
 		
-		slog.Info("Successfully logged film as watched", slog.String("imdbId", imdbId), slog.String("date", date[0].Format(time.DateOnly)))
+		slog.Info("Successfully logged film as watched", slog.String("imdbId", imdbId), slog.String("date", date))
 		time.Sleep(3 * time.Second)
 		return nil
 	}, config)
@@ -0,0 +1,57 @@
+package letterboxd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const _LIKE_SELECTOR string = "span.action-large.-like .action.-like"
+
+// SetFilmLiked sets the heart on the film page to the desired state
+func (u User) SetFilmLiked(film Film, liked bool) error {
+	var imdbId = film.id()
+	config := DefaultRetryConfig()
+	op := fmt.Sprintf("SetFilmLiked(imdbId=%s, liked=%t)", imdbId, liked)
+
+	return WithRetry(op, func() error {
+		var page, pageErr = u.openFilmPage(film)
+		if pageErr != nil {
+			return pageErr
+		}
+		defer page.Close()
+
+		slog.Debug("Looking for like button", slog.String("imdbId", imdbId), slog.String("selector", _LIKE_SELECTOR))
+		var likeLocator = page.Locator(_LIKE_SELECTOR)
+		var classes, likeLocatorErr = likeLocator.GetAttribute("class")
+		if likeLocatorErr != nil {
+			slog.Error("Failed to find like button", slog.String("imdbId", imdbId), slog.String("error", likeLocatorErr.Error()))
+			return &LetterboxdError{
+				Type:          ErrorTypeUI,
+				OriginalError: likeLocatorErr,
+				Context:       map[string]interface{}{"imdbId": imdbId, "selector": _LIKE_SELECTOR},
+				Retryable:     true,
+			}
+		}
+
+		if containsClass(classes, "-on") == liked {
+			slog.Info("Film already has desired like state", slog.String("imdbId", imdbId), slog.Bool("liked", liked))
+			return nil
+		}
+
+		slog.Debug("Attempting to click like button", slog.String("imdbId", imdbId))
+		if err := likeLocator.Click(); err != nil {
+			slog.Error("Failed to click like button", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
+			return &LetterboxdError{
+				Type:          ErrorTypeUI,
+				OriginalError: err,
+				Context:       map[string]interface{}{"imdbId": imdbId, "action": "click like button"},
+				Retryable:     true,
+			}
+		}
+		time.Sleep(3 * time.Second)
+
+		slog.Info("Completed SetFilmLiked operation", slog.String("imdbId", imdbId), slog.Bool("liked", liked))
+		return nil
+	}, config)
+}
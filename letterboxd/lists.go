@@ -0,0 +1,162 @@
+package letterboxd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// ListResult describes what AddToList did
+type ListResult string
+
+const (
+	// ListAdded means the film was added to an existing list
+	ListAdded ListResult = "added"
+	// ListCreated means the list was missing and was created with the film
+	ListCreated ListResult = "created"
+	// ListUnchanged means the film was already on the list
+	ListUnchanged ListResult = "unchanged"
+)
+
+var _ADD_TO_LIST_SELECTORS = []string{
+	"a:text('Add to lists…')",
+	"a:text('Add to lists...')",
+	".js-add-to-list",
+	"a.add-to-list",
+}
+
+var _NEW_LIST_SELECTORS = []string{
+	"#add-to-a-list-modal a:text('New list…')",
+	"#add-to-a-list-modal a:text('New list...')",
+	"#add-to-a-list-modal .js-new-list",
+}
+
+var _ADD_TO_LIST_SUBMIT_SELECTORS = []string{
+	"#add-to-a-list-modal button:text('Add')",
+	"#add-to-a-list-modal input[type=submit]",
+	"#add-to-a-list-modal .js-add-to-lists-submit",
+}
+
+var _SAVE_LIST_SELECTORS = []string{
+	"#list-edit-save",
+	"button:text('Save')",
+	"input[type=submit][value='Save']",
+}
+
+// AddToList adds the film to the user's list with the given name, creating
+// the list if it doesn't exist yet. The list is created at most once, even if
+// an attempt fails after submitting it.
+func (u User) AddToList(film Film, listName string) (ListResult, error) {
+	var imdbId = film.id()
+	config := DefaultRetryConfig()
+	op := fmt.Sprintf("AddToList(imdbId=%s, list=%s)", imdbId, listName)
+
+	var result ListResult
+	// Set once the new list has been submitted, after which it may exist even
+	// though the attempt failed
+	var submittedList bool
+	err := WithRetry(op, func() error {
+		var page, pageErr = u.openFilmPage(film)
+		if pageErr != nil {
+			return pageErr
+		}
+		defer page.Close()
+
+		var uiError = func(err error, action string) error {
+			slog.Error("Failed to add film to list",
+				slog.String("imdbId", imdbId),
+				slog.String("list", listName),
+				slog.String("action", action),
+				slog.String("error", err.Error()))
+			return &LetterboxdError{
+				Type:          ErrorTypeUI,
+				OriginalError: err,
+				Context:       map[string]interface{}{"imdbId": imdbId, "list": listName, "action": action},
+				Retryable:     true,
+			}
+		}
+
+		// Open the 'Add to lists' modal
+		var addLink, found = findVisible(page, _ADD_TO_LIST_SELECTORS)
+		if !found {
+			return uiError(fmt.Errorf("failed to find add to lists link"), "open add to lists")
+		}
+		if err := addLink.Click(); err != nil {
+			return uiError(err, "open add to lists")
+		}
+		time.Sleep(2 * time.Second)
+
+		var listItem = page.Locator("#add-to-a-list-modal .list-set-item").Filter(playwright.LocatorFilterOptions{
+			Has: page.GetByText(listName, playwright.PageGetByTextOptions{Exact: playwright.Bool(true)}),
+		}).First()
+
+		if visible, _ := listItem.IsVisible(); !visible {
+			if submittedList {
+				// Creating it again could leave two lists with the same name
+				return &LetterboxdError{
+					Type:          ErrorTypeUI,
+					OriginalError: fmt.Errorf("list not found after creating it"),
+					Context:       map[string]interface{}{"imdbId": imdbId, "list": listName, "action": "create list"},
+					Retryable:     false,
+				}
+			}
+
+			// List doesn't exist yet, create it from the modal so the film is prefilled
+			slog.Info("Letterboxd list not found, creating it", slog.String("list", listName))
+			newListLink, found := findVisible(page, _NEW_LIST_SELECTORS)
+			if !found {
+				return uiError(fmt.Errorf("failed to find new list link"), "create list")
+			}
+			if err := newListLink.Click(); err != nil {
+				return uiError(err, "create list")
+			}
+			if err := page.Locator("input#list-name").Fill(listName); err != nil {
+				return uiError(err, "set list name")
+			}
+			saveButton, found := findVisible(page, _SAVE_LIST_SELECTORS)
+			if !found {
+				return uiError(fmt.Errorf("failed to find save list button"), "save list")
+			}
+			submittedList = true
+			if err := saveButton.Click(); err != nil {
+				return uiError(err, "save list")
+			}
+			time.Sleep(3 * time.Second)
+
+			result = ListCreated
+			slog.Info("Created Letterboxd list with film", slog.String("imdbId", imdbId), slog.String("list", listName))
+			return nil
+		}
+
+		// Lists that already contain the film are marked as such in the modal
+		if classes, _ := listItem.GetAttribute("class"); containsClass(classes, "-on") || containsClass(classes, "film-in-list") {
+			result = ListUnchanged
+			if submittedList {
+				// Created with the film by an earlier attempt
+				result = ListCreated
+			}
+			slog.Info("Film already on Letterboxd list", slog.String("imdbId", imdbId), slog.String("list", listName))
+			return nil
+		}
+
+		if err := listItem.Click(); err != nil {
+			return uiError(err, "select list")
+		}
+		submitButton, found := findVisible(page, _ADD_TO_LIST_SUBMIT_SELECTORS)
+		if !found {
+			return uiError(fmt.Errorf("failed to find add to list button"), "add to list")
+		}
+		if err := submitButton.Click(); err != nil {
+			return uiError(err, "add to list")
+		}
+		time.Sleep(3 * time.Second)
+
+		result = ListAdded
+		slog.Info("Added film to Letterboxd list", slog.String("imdbId", imdbId), slog.String("list", listName))
+		return nil
+	}, config)
+
+	return result, err
+}
@@ -0,0 +1,58 @@
+package letterboxd
+
+import (
+	"container/list"
+	"sync"
+)
+
+// eventQueue holds the events waiting for a worker. It is unbounded, so
+// queueing never blocks the webhooks and pollers handing events over, e.g.
+// while the worker is paused or its circuit breaker is open.
+type eventQueue struct {
+	lock   sync.Mutex
+	events *list.List
+	ready  chan struct{} // Signalled when an event is pushed
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{
+		events: list.New(),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push adds an event to the back of the queue without blocking
+func (q *eventQueue) push(event Event) {
+	q.lock.Lock()
+	q.events.PushBack(event)
+	q.lock.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+		// The consumer is already due to check the queue
+	}
+}
+
+// pop removes the event at the front of the queue, waiting for one if it is
+// empty
+func (q *eventQueue) pop() Event {
+	for {
+		q.lock.Lock()
+		if front := q.events.Front(); front != nil {
+			q.events.Remove(front)
+			q.lock.Unlock()
+			return front.Value.(Event)
+		}
+		q.lock.Unlock()
+		<-q.ready
+	}
+}
+
+// len returns the number of queued events
+func (q *eventQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.events.Len()
+}
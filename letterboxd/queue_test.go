@@ -0,0 +1,64 @@
+package letterboxd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleEventDoesNotBlockWhileBreakerOpen(t *testing.T) {
+	var events = newEventQueue()
+	var worker = Worker{
+		debouncer: newDebouncer(events),
+		user:      User{username: "john_doe"},
+		events:    events,
+		breaker:   newCircuitBreaker(1, time.Hour, time.Hour),
+	}
+	worker.breaker.recordFailure(&LetterboxdError{Type: ErrorTypeNetwork, OriginalError: fmt.Errorf("connection refused")})
+	assert.Equal(t, BreakerOpen, worker.BreakerStatus().State)
+
+	// Nothing consumes the queue while the breaker is open
+	var done = make(chan struct{})
+	go func() {
+		for i := range 50 {
+			worker.HandleEvent(Event{Film: Film{ImdbId: fmt.Sprintf("tt%07d", i)}, Action: FilmLogged})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("HandleEvent blocked while the breaker was open")
+	}
+	assert.Equal(t, 50, worker.Backlog())
+
+	// Events are delivered in order once the worker carries on
+	for i := range 50 {
+		assert.Equal(t, fmt.Sprintf("tt%07d", i), events.pop().ImdbId)
+	}
+	assert.Equal(t, 0, worker.Backlog())
+}
+
+func TestEventQueuePopWaits(t *testing.T) {
+	var events = newEventQueue()
+	var popped = make(chan Event)
+	go func() {
+		popped <- events.pop()
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("pop returned from an empty queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	events.push(Event{Film: Film{ImdbId: "tt0133093"}})
+	select {
+	case event := <-popped:
+		assert.Equal(t, "tt0133093", event.ImdbId)
+	case <-time.After(time.Second):
+		t.Fatal("pop didn't return after a push")
+	}
+}
@@ -0,0 +1,131 @@
+package letterboxd
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Max number of events kept in a worker's review queue
+const _MAX_REVIEW_ITEMS int = 100
+
+// ReviewItem is an event that was held back because its Letterboxd film page
+// was missing or didn't match the media server's metadata, or that failed
+// for good after retries
+type ReviewItem struct {
+	ImdbId        string    `json:"imdb_id"`
+	Action        string    `json:"action"`
+	ExpectedTitle string    `json:"expected_title,omitempty"`
+	ExpectedYear  int       `json:"expected_year,omitempty"`
+	PageTitle     string    `json:"page_title,omitempty"`
+	PageYear      int       `json:"page_year,omitempty"`
+	PageURL       string    `json:"page_url,omitempty"`
+	Reason        ErrorType `json:"reason"`
+	Error         string    `json:"error"`
+	EventTime     time.Time `json:"event_time"`
+	QueuedAt      time.Time `json:"queued_at"`
+
+	// The original event, for retrying
+	event Event
+}
+
+type reviewQueue struct {
+	lock  sync.Mutex
+	items []ReviewItem
+}
+
+func newReviewItem(event Event, err error) ReviewItem {
+	var item = ReviewItem{
+		ImdbId:        event.id(),
+		Action:        event.Action.String(),
+		ExpectedTitle: event.Title,
+		ExpectedYear:  event.Year,
+		Reason:        ErrorTypeUnknown,
+		Error:         err.Error(),
+		EventTime:     event.Time,
+		QueuedAt:      time.Now(),
+		event:         event,
+	}
+
+	var lbErr *LetterboxdError
+	if errors.As(err, &lbErr) {
+		item.Reason = lbErr.Type
+		item.PageTitle, _ = lbErr.Context["pageTitle"].(string)
+		item.PageYear, _ = lbErr.Context["pageYear"].(int)
+		item.PageURL, _ = lbErr.Context["url"].(string)
+	}
+	return item
+}
+
+// add queues an item, replacing any earlier item for the same film
+func (q *reviewQueue) add(item ReviewItem) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.removeLocked(item.ImdbId)
+	if len(q.items) >= _MAX_REVIEW_ITEMS {
+		q.items = q.items[1:]
+	}
+	q.items = append(q.items, item)
+}
+
+// list returns the queued items, oldest first
+func (q *reviewQueue) list() []ReviewItem {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return append([]ReviewItem{}, q.items...)
+}
+
+// remove drops the item for a film, returning false if there was none
+func (q *reviewQueue) remove(imdbId string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.removeLocked(imdbId)
+}
+
+// take removes and returns the item for a film
+func (q *reviewQueue) take(imdbId string) (ReviewItem, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, item := range q.items {
+		if item.ImdbId == imdbId {
+			q.removeLocked(imdbId)
+			return item, true
+		}
+	}
+	return ReviewItem{}, false
+}
+
+func (q *reviewQueue) removeLocked(imdbId string) bool {
+	for i, item := range q.items {
+		if item.ImdbId == imdbId {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ReviewQueue returns the events waiting for manual review
+func (w *Worker) ReviewQueue() []ReviewItem {
+	return w.review.list()
+}
+
+// DismissReview removes a film from the review queue without acting on it
+func (w *Worker) DismissReview(imdbId string) bool {
+	return w.review.remove(imdbId)
+}
+
+// RetryReview removes a film from the review queue and queues its event again,
+// e.g. after adding a manual mapping
+func (w *Worker) RetryReview(imdbId string) bool {
+	var item, ok = w.review.take(imdbId)
+	if ok {
+		slog.Info("Retrying event from review queue",
+			slog.String("username", w.user.username),
+			slog.String("film", imdbId))
+		w.HandleEvent(item.event)
+	}
+	return ok
+}
@@ -2,14 +2,22 @@ package letterboxd
 
 import (
 	"log/slog"
+	"sync"
 	"time"
 )
 
 // Status represents the current status of a Letterboxd worker
 type Status struct {
-	Username    string `json:"username"`
-	IsConnected bool   `json:"is_connected"`
-	LastChecked time.Time `json:"last_checked"`
+	Username    string        `json:"username"`
+	IsConnected bool          `json:"is_connected"`
+	LastChecked time.Time     `json:"last_checked"`
+	Breaker     BreakerStatus `json:"breaker"`
+}
+
+// statusCache holds the result of the most recent background probe
+type statusCache struct {
+	lock   sync.RWMutex
+	status Status
 }
 
 // CheckStatus tests the connection to Letterboxd and returns the current status
@@ -22,20 +30,66 @@ func (w *Worker) CheckStatus() Status {
 
 	// Check if the user is logged in (page can be nil)
 	isLoggedIn := page != nil && w.user.isLoggedIn(page)
-	
+
 	status := Status{
 		Username:    w.user.username,
 		IsConnected: isLoggedIn,
 		LastChecked: time.Now(),
+		Breaker:     w.breaker.Status(),
 	}
 
-	if !isLoggedIn {
+	if !isLoggedIn && status.Breaker.State == BreakerClosed {
 		slog.Warn("Letterboxd worker not logged in", slog.String("username", w.user.username))
-		// Attempt to login again if not connected
+		// Attempt to login again if not connected; while the breaker is open
+		// the worker's own probes take care of logging back in
 		go func() {
+			w.control.session.Lock()
+			defer w.control.session.Unlock()
 			w.user.Login()
 		}()
 	}
 
 	return status
-}
\ No newline at end of file
+}
+
+// Status returns the status recorded by the most recent background probe,
+// with the breaker state kept current. LastChecked is zero until the first
+// probe has completed.
+func (w *Worker) Status() Status {
+	w.status.lock.RLock()
+	var status = w.status.status
+	w.status.lock.RUnlock()
+
+	status.Username = w.user.username
+	status.Breaker = w.breaker.Status()
+	return status
+}
+
+// StartProber periodically checks the worker's connection to Letterboxd in the
+// background so that Status never has to touch the browser
+func (w *Worker) StartProber(interval time.Duration) {
+	go func() {
+		for {
+			w.recordStatus(w.CheckStatus())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// recordStatus caches a newly found status, reporting it if the login state
+// changed
+func (w *Worker) recordStatus(status Status) {
+	w.status.lock.Lock()
+	var previous = w.status.status
+	w.status.status = status
+	w.status.lock.Unlock()
+
+	// The first probe only reports a logged out account, later ones any change
+	var changed = status.IsConnected != previous.IsConnected
+	if previous.LastChecked.IsZero() {
+		changed = !status.IsConnected
+	}
+	if changed && w.options.OnStatusChange != nil {
+		w.options.OnStatusChange(status)
+	}
+}
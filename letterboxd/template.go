@@ -0,0 +1,68 @@
+package letterboxd
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Placeholders such as {{year}} in user-configured templates
+var templateVariablePattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// RenderTemplate replaces {{name}} placeholders with their values; unknown
+// placeholders render empty
+func RenderTemplate(template string, vars map[string]string) string {
+	var rendered = templateVariablePattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		var name = templateVariablePattern.FindStringSubmatch(placeholder)[1]
+		return vars[strings.ToLower(name)]
+	})
+	return strings.TrimSpace(rendered)
+}
+
+// renderTags renders a comma-separated tag template into Letterboxd tags,
+// lowercased with spaces replaced by hyphens, dropping empty tags
+func renderTags(template string, vars map[string]string) []string {
+	var tags []string
+	for _, tag := range strings.Split(RenderTemplate(template, vars), ",") {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// serverName returns the display name of a media server, e.g. "plex" -> "Plex"
+func serverName(server string) string {
+	if server == "" {
+		return ""
+	}
+	return strings.ToUpper(server[:1]) + server[1:]
+}
+
+// TemplateVars returns the values available to templates for an event
+func TemplateVars(event Event) map[string]string {
+	var watched = event.Time
+	if watched.IsZero() {
+		watched = time.Now()
+	}
+
+	var vars = map[string]string{
+		"year":       strconv.Itoa(watched.Year()),
+		"month":      watched.Format("01"),
+		"date":       watched.Format(time.DateOnly),
+		"title":      event.Title,
+		"imdb":       event.ImdbId,
+		"server":     serverName(event.Server),
+		"player":     event.Player,
+		"device":     event.Device,
+		"library":    event.Library,
+		"resolution": event.Resolution,
+	}
+	if event.Year != 0 {
+		vars["release_year"] = strconv.Itoa(event.Year)
+	}
+	return vars
+}
@@ -0,0 +1,42 @@
+package letterboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	var event = Event{
+		Film:     Film{ImdbId: "tt0133093", Title: "The Matrix", Year: 1999, Server: "plex"},
+		Playback: Playback{Player: "Plex for LG", Device: "Living Room", Resolution: "4k"},
+		Time:     time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"Home Cinema {{year}}", "Home Cinema 2026"},
+		{"Watched on {{ server }} in {{month}}/{{year}}", "Watched on Plex in 10/2026"},
+		{"Watched on {{server}} ({{device}})", "Watched on Plex (Living Room)"},
+		{"{{title}} ({{release_year}})", "The Matrix (1999)"},
+		{"{{unknown}} Films", "Films"},
+		{"No placeholders", "No placeholders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RenderTemplate(tt.template, TemplateVars(event)))
+		})
+	}
+}
+
+func TestRenderTags(t *testing.T) {
+	var vars = map[string]string{"server": "Plex", "resolution": "4k", "device": "Living Room", "library": ""}
+
+	assert.Equal(t, []string{"plex", "4k", "living-room"}, renderTags("{{server}}, {{resolution}}, {{device}}", vars))
+	assert.Equal(t, []string{"plex"}, renderTags("{{server}}, {{library}}, plex", vars))
+	assert.Empty(t, renderTags("", vars))
+}
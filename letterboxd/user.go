@@ -1,16 +1,20 @@
 package letterboxd
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
+	"sync"
 
 	"github.com/playwright-community/playwright-go"
 )
 
 var browser playwright.Browser
 
-func init() {
+// Launch installs (if necessary) and starts the shared Firefox instance used by
+// all Letterboxd users. It must be called before NewUser.
+func Launch() error {
 	slog.Info("Initializing Playwright for Letterboxd integration...")
 
 	// Explicitly set the browser path to match what we set up in Dockerfile and entrypoint
@@ -74,9 +78,9 @@ func init() {
 		}
 	}
 
-	// If all attempts failed, we have to panic
+	// If all attempts failed, there is nothing left to try
 	if runErr != nil {
-		panic("Failed to initialize Playwright after multiple attempts: " + runErr.Error())
+		return fmt.Errorf("failed to initialize Playwright after multiple attempts: %w", runErr)
 	}
 
 	var headless = true
@@ -106,8 +110,14 @@ func init() {
 	} else {
 		slog.Error("Failed to launch Firefox browser",
 			slog.String("error", err.Error()))
-		panic(err)
+		return err
 	}
+	return nil
+}
+
+// BrowserConnected returns true if the shared Firefox instance is running
+func BrowserConnected() bool {
+	return browser != nil && browser.IsConnected()
 }
 
 func NewUser(username string, password string) User {
@@ -125,13 +135,15 @@ func NewUser(username string, password string) User {
 		username,
 		password,
 		context,
+		&sync.Mutex{},
 	}
 }
 
 type User struct {
-	username string
-	password string
-	context  playwright.BrowserContext
+	username  string
+	password  string
+	context   playwright.BrowserContext
+	loginLock *sync.Mutex
 }
 
 func (l User) newPage(url string) playwright.Page {
@@ -0,0 +1,155 @@
+package letterboxd
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// Max difference between release years before a film page is considered a mismatch
+const _MAX_YEAR_DIFFERENCE int = 1
+
+// Letterboxd film pages use "Title (Year)" as their og:title
+var ogTitlePattern = regexp.MustCompile(`^(.*) \((\d{4})\)$`)
+
+// parseOgTitle splits a Letterboxd og:title into the film title and release year
+func parseOgTitle(ogTitle string) (string, int) {
+	var match = ogTitlePattern.FindStringSubmatch(strings.TrimSpace(ogTitle))
+	if match == nil {
+		return strings.TrimSpace(ogTitle), 0
+	}
+	var year, _ = strconv.Atoi(match[2])
+	return match[1], year
+}
+
+// Separates a title from its subtitle, e.g. "Star Wars: Episode IV - A New Hope"
+var subtitleSeparatorPattern = regexp.MustCompile(`:\s|\s[-–—]\s`)
+
+// Articles moved to the end of sorted titles, e.g. "Matrix, The"
+var trailingArticlePattern = regexp.MustCompile(`(?i),\s*(the|a|an)$`)
+
+// normalizeTitle lowercases a title and strips punctuation, whitespace and a
+// leading article so cosmetic differences between sources don't count
+func normalizeTitle(title string) string {
+	title = trailingArticlePattern.ReplaceAllString(strings.TrimSpace(title), "")
+	var builder strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' {
+			builder.WriteRune(r)
+		}
+	}
+	var normalized = strings.Join(strings.Fields(builder.String()), " ")
+	for _, article := range []string{"the ", "a ", "an "} {
+		normalized = strings.TrimPrefix(normalized, article)
+	}
+	return strings.ReplaceAll(normalized, " ", "")
+}
+
+// matchFilmIdentity returns true if the Letterboxd title and year agree with
+// the media server's metadata. Unknown values on either side always match.
+func matchFilmIdentity(film Film, pageTitle string, pageYear int) bool {
+	if film.Year != 0 && pageYear != 0 {
+		var difference = film.Year - pageYear
+		if difference > _MAX_YEAR_DIFFERENCE || difference < -_MAX_YEAR_DIFFERENCE {
+			return false
+		}
+	}
+
+	var expected, actual = normalizeTitle(film.Title), normalizeTitle(pageTitle)
+	if expected != "" && actual != "" {
+		// Only whole titles match, so short titles like "It" don't match "It Follows"
+		return expected == actual ||
+			normalizeTitle(mainTitle(film.Title)) == actual ||
+			expected == normalizeTitle(mainTitle(pageTitle))
+	}
+	return true
+}
+
+// mainTitle returns a title without its subtitle
+func mainTitle(title string) string {
+	if location := subtitleSeparatorPattern.FindStringIndex(title); location != nil {
+		return title[:location[0]]
+	}
+	return title
+}
+
+// verifyFilmPage checks that the loaded page is a Letterboxd film page for the
+// expected film, so a bad IMDb ID never acts on the wrong film
+func (u User) verifyFilmPage(page playwright.Page, film Film) error {
+	var url = page.URL()
+	var context = map[string]interface{}{
+		"imdbId":        film.id(),
+		"expectedTitle": film.Title,
+		"expectedYear":  film.Year,
+		"url":           url,
+	}
+
+	var result, err = page.Evaluate(`() => {
+		const meta = document.querySelector('meta[property="og:title"]');
+		const type = document.querySelector('meta[property="og:type"]');
+		return type && type.content === 'video.movie' && meta ? meta.content : '';
+	}`)
+	if err != nil {
+		return &LetterboxdError{
+			Type:          ErrorTypeUI,
+			OriginalError: err,
+			Context:       context,
+			Retryable:     true,
+		}
+	}
+
+	var ogTitle, _ = result.(string)
+	if ogTitle == "" || !strings.Contains(url, "/film/") {
+		slog.Warn("Letterboxd film page not found", slog.String("imdbId", film.id()), slog.String("url", url))
+		return &LetterboxdError{
+			Type:          ErrorTypeNotFound,
+			OriginalError: fmt.Errorf("no Letterboxd film page for %s", film.id()),
+			Context:       context,
+			Retryable:     false,
+		}
+	}
+
+	var pageTitle, pageYear = parseOgTitle(ogTitle)
+	context["pageTitle"] = pageTitle
+	context["pageYear"] = pageYear
+
+	// A manual mapping is trusted, e.g. regional cuts that are titled differently
+	if film.Slug == "" && !matchFilmIdentity(film, pageTitle, pageYear) {
+		slog.Warn("Letterboxd film page does not match media server metadata",
+			slog.String("imdbId", film.id()),
+			slog.String("expectedTitle", film.Title),
+			slog.Int("expectedYear", film.Year),
+			slog.String("pageTitle", pageTitle),
+			slog.Int("pageYear", pageYear))
+		return &LetterboxdError{
+			Type:          ErrorTypeMismatch,
+			OriginalError: fmt.Errorf("expected %q (%d), found %q (%d)", film.Title, film.Year, pageTitle, pageYear),
+			Context:       context,
+			Retryable:     false,
+		}
+	}
+
+	slog.Debug("Verified Letterboxd film page",
+		slog.String("imdbId", film.id()),
+		slog.String("pageTitle", pageTitle),
+		slog.Int("pageYear", pageYear))
+	return nil
+}
+
+// VerifyFilm loads and verifies the film page without changing anything
+func (u User) VerifyFilm(film Film) error {
+	op := fmt.Sprintf("VerifyFilm(imdbId=%s)", film.id())
+	return WithRetry(op, func() error {
+		var page, err = u.openFilmPage(film)
+		if err != nil {
+			return err
+		}
+		page.Close()
+		return nil
+	}, DefaultRetryConfig())
+}
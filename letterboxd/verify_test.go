@@ -0,0 +1,60 @@
+package letterboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOgTitle(t *testing.T) {
+	tests := []struct {
+		ogTitle       string
+		expectedTitle string
+		expectedYear  int
+	}{
+		{"The Matrix (1999)", "The Matrix", 1999},
+		{"Alien³ (1992)", "Alien³", 1992},
+		{"Blade Runner 2049 (2017)", "Blade Runner 2049", 2017},
+		{"Letterboxd", "Letterboxd", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ogTitle, func(t *testing.T) {
+			title, year := parseOgTitle(tt.ogTitle)
+			assert.Equal(t, tt.expectedTitle, title)
+			assert.Equal(t, tt.expectedYear, year)
+		})
+	}
+}
+
+func TestMatchFilmIdentity(t *testing.T) {
+	tests := []struct {
+		name      string
+		film      Film
+		pageTitle string
+		pageYear  int
+		expected  bool
+	}{
+		{"Exact match", Film{Title: "The Matrix", Year: 1999}, "The Matrix", 1999, true},
+		{"Punctuation and article differences", Film{Title: "Matrix, The", Year: 1999}, "The Matrix", 1999, true},
+		{"Year off by one", Film{Title: "The Matrix", Year: 2000}, "The Matrix", 1999, true},
+		{"Year mismatch", Film{Title: "The Matrix", Year: 2003}, "The Matrix", 1999, false},
+		{"Title mismatch", Film{Title: "Inception", Year: 2010}, "Shutter Island", 2010, false},
+		{"Unknown metadata", Film{ImdbId: "tt0133093"}, "The Matrix", 1999, true},
+		{"Unknown page year", Film{Title: "The Matrix", Year: 1999}, "The Matrix", 0, true},
+		{"Subtitle on the media server", Film{Title: "Star Wars: Episode IV - A New Hope", Year: 1977}, "Star Wars", 1977, true},
+		{"Subtitle on Letterboxd", Film{Title: "Borat", Year: 2006}, "Borat: Cultural Learnings of America for Make Benefit Glorious Nation of Kazakhstan", 2006, true},
+		{"Short title prefix", Film{Title: "It", Year: 2014}, "It Follows", 2014, false},
+		{"Short title inside", Film{Title: "Up", Year: 2009}, "Up in the Air", 2009, false},
+		{"Short title suffix", Film{Title: "Us", Year: 2019}, "Love Us", 2018, false},
+		{"Short title within word", Film{Title: "Her", Year: 2013}, "Brother", 2013, false},
+		{"Short title found in longer title", Film{Title: "It Follows", Year: 2014}, "It", 2014, false},
+		{"Different subtitles", Film{Title: "Kill Bill: Vol. 1", Year: 2003}, "Kill Bill: Vol. 2", 2004, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchFilmIdentity(tt.film, tt.pageTitle, tt.pageYear))
+		})
+	}
+}
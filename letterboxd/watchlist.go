@@ -0,0 +1,62 @@
+package letterboxd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const _WATCHLIST_SELECTOR string = "span.action-large.-watchlist .action.-watchlist"
+
+// RemoveFromWatchlist removes the film from the user's watchlist, returning
+// false if it wasn't on the watchlist
+func (u User) RemoveFromWatchlist(film Film) (bool, error) {
+	var imdbId = film.id()
+	config := DefaultRetryConfig()
+	op := fmt.Sprintf("RemoveFromWatchlist(imdbId=%s)", imdbId)
+
+	var removed bool
+	err := WithRetry(op, func() error {
+		var page, pageErr = u.openFilmPage(film)
+		if pageErr != nil {
+			return pageErr
+		}
+		defer page.Close()
+
+		slog.Debug("Looking for watchlist button", slog.String("imdbId", imdbId), slog.String("selector", _WATCHLIST_SELECTOR))
+		var watchlistLocator = page.Locator(_WATCHLIST_SELECTOR)
+		var classes, watchlistLocatorErr = watchlistLocator.GetAttribute("class")
+		if watchlistLocatorErr != nil {
+			slog.Error("Failed to find watchlist button", slog.String("imdbId", imdbId), slog.String("error", watchlistLocatorErr.Error()))
+			return &LetterboxdError{
+				Type:          ErrorTypeUI,
+				OriginalError: watchlistLocatorErr,
+				Context:       map[string]interface{}{"imdbId": imdbId, "selector": _WATCHLIST_SELECTOR},
+				Retryable:     true,
+			}
+		}
+
+		if !containsClass(classes, "-on") {
+			slog.Info("Film is not on watchlist", slog.String("imdbId", imdbId))
+			return nil
+		}
+
+		slog.Debug("Attempting to click watchlist button", slog.String("imdbId", imdbId))
+		if err := watchlistLocator.Click(); err != nil {
+			slog.Error("Failed to click watchlist button", slog.String("imdbId", imdbId), slog.String("error", err.Error()))
+			return &LetterboxdError{
+				Type:          ErrorTypeUI,
+				OriginalError: err,
+				Context:       map[string]interface{}{"imdbId": imdbId, "action": "click watchlist button"},
+				Retryable:     true,
+			}
+		}
+		time.Sleep(3 * time.Second)
+
+		removed = true
+		slog.Info("Removed film from watchlist", slog.String("imdbId", imdbId))
+		return nil
+	}, config)
+
+	return removed, err
+}
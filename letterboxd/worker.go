@@ -1,11 +1,15 @@
 package letterboxd
 
 import (
+	"fmt"
 	"log/slog"
 	"time"
+
+	"emboxd/mapping"
 )
 
-const _EVENT_BUFFER_SIZE int = 10
+// Max times an event is attempted before it is dropped for good
+const _MAX_EVENT_DELIVERIES int = 5
 
 type Action int
 
@@ -13,33 +17,115 @@ const (
 	FilmUnwatched Action = iota
 	FilmWatched
 	FilmLogged
+	FilmLiked
+	FilmUnliked
 )
 
+var _STRING_BY_ACTION = map[Action]string{
+	FilmUnwatched: "unwatched",
+	FilmWatched:   "watched",
+	FilmLogged:    "logged",
+	FilmLiked:     "liked",
+	FilmUnliked:   "unliked",
+}
+
+func (a Action) String() string {
+	if str, ok := _STRING_BY_ACTION[a]; ok {
+		return str
+	}
+	return "unknown"
+}
+
+// Playback describes where a film was watched, for diary and list templates
+type Playback struct {
+	Player     string // Client application, e.g. "Plex for LG"
+	Device     string // Device name, e.g. "Living Room"
+	Library    string
+	Resolution string // e.g. "4k", "1080p"
+}
+
 type Event struct {
-	ImdbId string
+	Film
+	Playback
 	Action Action
 	Time   time.Time
+	// Stars from 0.5 to 5 for the diary entry, zero for none
+	Rating float64
+}
+
+// Outcome describes the result of processing an event on Letterboxd
+type Outcome struct {
+	Username string
+	Event    Event
+	Action   string // Description of the Letterboxd action taken
+	Skipped  string // Reason the action was skipped, if it was
+	DryRun   bool   // Nothing was changed on Letterboxd
+	Err      error
+	Details  map[string]interface{}
+	Duration time.Duration
+}
+
+// WorkerOptions holds the per-account settings for a Worker
+type WorkerOptions struct {
+	// Create diary entries instead of just marking films as watched
+	LogFilms bool
+	// Remove films from the watchlist once they are watched or logged
+	RemoveFromWatchlist bool
+	// Don't log films that already have a diary entry this close to the watch,
+	// e.g. logged by hand on the phone; zero disables the check
+	DuplicateWindow time.Duration
+	// Verify film pages and record what would be done without changing anything
+	DryRun bool
+	// Like and unlike films when they are favourited on the media server
+	SyncLikes bool
+	// Name template of a list to add watched films to, e.g. "Home Cinema {{year}}"
+	ListTemplate string
+	// Comma-separated diary tag template, e.g. "{{server}}, {{resolution}}"
+	DiaryTagsTemplate string
+	// Diary review text template, e.g. "Watched on {{server}} ({{player}})"
+	DiaryReviewTemplate string
+	// Manual IMDb/TMDb/item to Letterboxd slug overrides
+	Mappings *mapping.Store
+	// Called with the final outcome of every processed event
+	OnOutcome func(Outcome)
+	// Called when a background probe finds the login state has changed, or
+	// finds the account logged out on the first probe
+	OnStatusChange func(Status)
 }
 
 type Worker struct {
 	debouncer
-	user     User
-	channel  chan Event
-	logFilms bool
+	user    User
+	events  *eventQueue
+	options WorkerOptions
+	diary   *DiaryFeed
+	breaker *circuitBreaker
+	status  *statusCache
+	review  *reviewQueue
+	control *workerControl
 }
 
-func NewWorker(username string, password string, logFilms bool) Worker {
-	var channel = make(chan Event, _EVENT_BUFFER_SIZE)
+func NewWorker(username string, password string, options WorkerOptions) Worker {
+	var events = newEventQueue()
 	return Worker{
 		debouncer: newDebouncer(
-			channel,
+			events,
 		),
 		user: NewUser(
 			username,
 			password,
 		),
-		channel: channel,
-		logFilms: logFilms,
+		events:  events,
+		options: options,
+		diary:   NewDiaryFeed(),
+		breaker: newCircuitBreaker(
+			_BREAKER_FAILURE_THRESHOLD,
+			_BREAKER_INITIAL_BACKOFF,
+			_BREAKER_MAX_BACKOFF,
+		),
+		status:  &statusCache{},
+		review:  &reviewQueue{},
+		control: &workerControl{},
 	}
 }
 
@@ -51,56 +137,326 @@ func (w *Worker) Start() {
 	go w.run()
 }
 
+// Backlog returns the number of events waiting to be processed
+func (w *Worker) Backlog() int {
+	return w.events.len()
+}
+
+// BreakerStatus returns a snapshot of the worker's circuit breaker
+func (w *Worker) BreakerStatus() BreakerStatus {
+	return w.breaker.Status()
+}
+
 func (w *Worker) run() {
 	// Initial login
+	w.control.session.Lock()
 	err := w.user.Login()
+	w.control.session.Unlock()
 	if err != nil {
 		slog.Error("Failed to login during worker initialization",
 			slog.String("username", w.user.username),
 			slog.String("error", err.Error()))
+		if countsTowardBreaker(err) {
+			w.breaker.recordFailure(err)
+		}
 	}
 
+	// Event held back for redelivery after a Letterboxd outage
+	var pending *Event
+	var deliveries int
+
 	for {
-		var event = <-w.channel
-
-		// Process each event with proper error handling
-		var actionStr string
-		var err error
-
-		switch event.Action {
-		case FilmWatched:
-			// If logFilms is enabled, use LogFilmWatched instead of SetFilmWatched
-			if w.logFilms {
-				actionStr = "log film as watched"
-				err = w.user.LogFilmWatched(event.ImdbId)
+		w.awaitResume()
+		w.awaitBreaker()
+
+		var event Event
+		if pending != nil {
+			event = *pending
+			pending = nil
+		} else {
+			event = w.events.pop()
+			deliveries = 0
+		}
+		if w.Paused() {
+			// Paused while waiting for the event
+			pending = &event
+			continue
+		}
+		deliveries++
+
+		var start = time.Now()
+		var details = make(map[string]interface{})
+		w.control.session.Lock()
+		var actionStr, skipped, err = w.process(event, details)
+		w.control.session.Unlock()
+		if actionStr == "" {
+			// Ignored without going to Letterboxd
+			w.emit(Outcome{Event: event, Skipped: skipped, Details: details}, start)
+			continue
+		}
+
+		if err == nil {
+			w.breaker.recordSuccess()
+			if skipped != "" {
+				slog.Info("Skipped event",
+					slog.String("action", actionStr),
+					slog.String("imdbId", event.ImdbId),
+					slog.String("reason", skipped))
 			} else {
-				actionStr = "mark film as watched"
-				err = w.user.SetFilmWatched(event.ImdbId, true)
+				slog.Info("Successfully processed event",
+					slog.String("action", actionStr),
+					slog.String("imdbId", event.ImdbId),
+					slog.Time("eventTime", event.Time))
 			}
-		case FilmUnwatched:
-			actionStr = "mark film as unwatched"
-			err = w.user.SetFilmWatched(event.ImdbId, false)
-		case FilmLogged:
-			actionStr = "log film as watched"
-			err = w.user.LogFilmWatched(event.ImdbId)
-		default:
-			slog.Error("Unknown event action",
-				slog.Int("action", int(event.Action)),
-				slog.String("imdbId", event.ImdbId))
+			w.emit(Outcome{Event: event, Action: actionStr, Skipped: skipped, Details: details}, start)
 			continue
 		}
 
-		if err != nil {
-			slog.Error("Failed to process event",
-				slog.String("action", actionStr),
+		slog.Error("Failed to process event",
+			slog.String("action", actionStr),
+			slog.String("imdbId", event.ImdbId),
+			slog.String("error", err.Error()),
+			slog.Time("eventTime", event.Time),
+			slog.Int("delivery", deliveries))
+
+		if IsVerificationError(err) {
+			slog.Warn("Film page could not be verified, queued for review",
+				slog.String("imdbId", event.ImdbId),
+				slog.String("title", event.Title),
+				slog.Int("year", event.Year))
+			w.review.add(newReviewItem(event, err))
+			details["review"] = true
+			w.emit(Outcome{Event: event, Action: actionStr, Err: err, Details: details}, start)
+			continue
+		}
+
+		if countsTowardBreaker(err) {
+			if w.breaker.recordFailure(err) {
+				slog.Warn("Letterboxd circuit breaker open, pausing event consumption",
+					slog.String("username", w.user.username),
+					slog.Duration("retryIn", w.breaker.untilProbe()))
+			}
+			if deliveries < _MAX_EVENT_DELIVERIES {
+				pending = &event
+				continue
+			}
+			slog.Error("Dropping event after repeated failures",
 				slog.String("imdbId", event.ImdbId),
+				slog.Int("deliveries", deliveries))
+		}
+
+		// Kept for retrying by hand once the cause is fixed
+		w.review.add(newReviewItem(event, err))
+		details["review"] = true
+		details["deliveries"] = deliveries
+		w.emit(Outcome{Event: event, Action: actionStr, Err: err, Details: details}, start)
+	}
+}
+
+// emit reports the final outcome of an event to the OnOutcome callback
+func (w *Worker) emit(outcome Outcome, start time.Time) {
+	w.recordActivity(outcome)
+	if w.options.OnOutcome == nil {
+		return
+	}
+	outcome.Username = w.user.username
+	outcome.DryRun = w.options.DryRun
+	outcome.Duration = time.Since(start)
+	w.options.OnOutcome(outcome)
+}
+
+// awaitBreaker blocks while the circuit breaker is open, probing Letterboxd
+// each time the backoff elapses until it closes again
+func (w *Worker) awaitBreaker() {
+	for w.breaker.State() != BreakerClosed {
+		if wait := w.breaker.untilProbe(); wait > 0 {
+			time.Sleep(wait)
+			continue
+		}
+
+		w.breaker.halfOpen()
+		slog.Info("Probing Letterboxd after outage", slog.String("username", w.user.username))
+		w.control.session.Lock()
+		var err = w.user.probe()
+		w.control.session.Unlock()
+		if err != nil {
+			w.breaker.recordFailure(err)
+			slog.Warn("Letterboxd probe failed, keeping circuit breaker open",
+				slog.String("username", w.user.username),
 				slog.String("error", err.Error()),
-				slog.Time("eventTime", event.Time))
+				slog.Duration("retryIn", w.breaker.untilProbe()))
 		} else {
-			slog.Info("Successfully processed event",
-				slog.String("action", actionStr),
-				slog.String("imdbId", event.ImdbId),
-				slog.Time("eventTime", event.Time))
+			w.breaker.recordSuccess()
+			slog.Info("Letterboxd reachable again, resuming event consumption",
+				slog.String("username", w.user.username))
+		}
+	}
+}
+
+// Skip reasons for events ignored without going to Letterboxd
+const (
+	SkipIgnoredByMapping string = "ignored_by_mapping"
+	SkipNoFilmId         string = "no_film_id"
+	SkipLikesDisabled    string = "likes_disabled"
+	SkipUnknownAction    string = "unknown_action"
+)
+
+// process performs the Letterboxd action for an event, returning a description
+// of the action (empty if the event was ignored), the reason it was skipped or
+// ignored if it was and any error. Follow-up results are recorded in details.
+func (w *Worker) process(event Event, details map[string]interface{}) (string, string, error) {
+	if w.options.Mappings != nil {
+		if target, ok := w.options.Mappings.Resolve(event.mappingIds()); ok {
+			if target == mapping.Ignore {
+				slog.Info("Ignoring film per manual mapping", slog.String("film", event.id()))
+				return "", SkipIgnoredByMapping, nil
+			}
+			slog.Debug("Using manual Letterboxd mapping", slog.String("film", event.id()), slog.String("slug", target))
+			event.Slug = target
+			details["slug"] = target
+		}
+	}
+
+	if event.ImdbId == "" && event.Slug == "" {
+		slog.Warn("No IMDb ID or manual mapping for film, ignoring event", slog.String("film", event.id()))
+		return "", SkipNoFilmId, nil
+	}
+
+	// The action is decided first, so dry runs can stop short of performing it
+	var actionStr, verb string
+	var perform func() error
+	var followUps bool
+	switch event.Action {
+	case FilmWatched, FilmLogged:
+		// If logFilms is enabled, use LogFilmWatched instead of SetFilmWatched
+		if event.Action == FilmLogged || w.options.LogFilms {
+			actionStr, verb = "log film as watched", "log"
+			if reason := w.findManualLog(event, details); reason != "" {
+				return actionStr, reason, nil
+			}
+			var entry = w.diaryEntry(event, details)
+			perform = func() error { return w.user.LogFilmWatched(event.Film, entry) }
+		} else {
+			actionStr, verb = "mark film as watched", "mark as watched"
+			perform = func() error { return w.user.SetFilmWatched(event.Film, true) }
+		}
+		followUps = true
+	case FilmUnwatched:
+		actionStr, verb = "mark film as unwatched", "mark as unwatched"
+		perform = func() error { return w.user.SetFilmWatched(event.Film, false) }
+	case FilmLiked, FilmUnliked:
+		if !w.options.SyncLikes {
+			slog.Debug("Like sync disabled, ignoring event", slog.String("imdbId", event.ImdbId), slog.String("action", event.Action.String()))
+			return "", SkipLikesDisabled, nil
+		}
+		var liked = event.Action == FilmLiked
+		if liked {
+			actionStr, verb = "like film", "like"
+		} else {
+			actionStr, verb = "unlike film", "unlike"
 		}
+		perform = func() error { return w.user.SetFilmLiked(event.Film, liked) }
+	default:
+		slog.Error("Unknown event action",
+			slog.Int("action", int(event.Action)),
+			slog.String("imdbId", event.ImdbId))
+		return "", SkipUnknownAction, nil
+	}
+
+	if w.options.DryRun {
+		return w.dryRun(event, verb, followUps, details)
+	}
+
+	var err = perform()
+	if err == nil && followUps && w.options.RemoveFromWatchlist {
+		w.removeFromWatchlist(event, details)
+	}
+	if err == nil && followUps && w.options.ListTemplate != "" {
+		w.addToList(event, details)
+	}
+	return actionStr, "", err
+}
+
+// dryRun verifies the film page without changing anything on Letterboxd,
+// describing the action that would have been taken instead
+func (w *Worker) dryRun(event Event, verb string, followUps bool, details map[string]interface{}) (string, string, error) {
+	details["dry_run"] = true
+	var actionStr = fmt.Sprintf("would %s %s on %s", verb, event.id(), event.Time.Format(time.DateOnly))
+	if err := w.user.VerifyFilm(event.Film); err != nil {
+		return actionStr, "", err
+	}
+
+	if followUps && w.options.RemoveFromWatchlist {
+		details["watchlist"] = "would_remove"
+	}
+	if followUps && w.options.ListTemplate != "" {
+		details["list"] = RenderTemplate(w.options.ListTemplate, TemplateVars(event))
+		details["list_result"] = "would_add"
+	}
+	slog.Info("Dry run, not changing Letterboxd",
+		slog.String("username", w.user.username),
+		slog.String("action", actionStr))
+	return actionStr, "", nil
+}
+
+// diaryEntry renders the diary tag and review templates for an event
+func (w *Worker) diaryEntry(event Event, details map[string]interface{}) DiaryEntry {
+	var vars = TemplateVars(event)
+	var entry = DiaryEntry{
+		Date:   event.Time,
+		Tags:   renderTags(w.options.DiaryTagsTemplate, vars),
+		Review: RenderTemplate(w.options.DiaryReviewTemplate, vars),
+		Rating: event.Rating,
+	}
+	if len(entry.Tags) > 0 {
+		details["diary_tags"] = entry.Tags
+	}
+	if entry.Review != "" {
+		details["diary_review"] = entry.Review
+	}
+	if entry.Rating > 0 {
+		details["rating"] = entry.Rating
+	}
+	return entry
+}
+
+// removeFromWatchlist clears a watched film from the watchlist. Failures are
+// recorded but don't fail the event, since the film itself was synced.
+func (w *Worker) removeFromWatchlist(event Event, details map[string]interface{}) {
+	var removed, err = w.user.RemoveFromWatchlist(event.Film)
+	switch {
+	case err != nil:
+		slog.Warn("Failed to remove film from watchlist",
+			slog.String("imdbId", event.ImdbId),
+			slog.String("error", err.Error()))
+		details["watchlist"] = "failed"
+		details["watchlist_error"] = err.Error()
+	case removed:
+		details["watchlist"] = "removed"
+	default:
+		details["watchlist"] = "not_on_watchlist"
+	}
+}
+
+// addToList adds a watched film to the list named by the list template.
+// Failures are recorded but don't fail the event.
+func (w *Worker) addToList(event Event, details map[string]interface{}) {
+	var listName = RenderTemplate(w.options.ListTemplate, TemplateVars(event))
+	if listName == "" {
+		slog.Warn("List template rendered an empty name, skipping", slog.String("template", w.options.ListTemplate))
+		return
+	}
+	details["list"] = listName
+
+	var result, err = w.user.AddToList(event.Film, listName)
+	if err != nil {
+		slog.Warn("Failed to add film to list",
+			slog.String("imdbId", event.ImdbId),
+			slog.String("list", listName),
+			slog.String("error", err.Error()))
+		details["list_result"] = "failed"
+		details["list_error"] = err.Error()
+		return
 	}
+	details["list_result"] = string(result)
 }
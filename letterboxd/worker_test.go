@@ -0,0 +1,39 @@
+package letterboxd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"emboxd/mapping"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessIgnoredEvents(t *testing.T) {
+	var mappings, err = mapping.NewStore(filepath.Join(t.TempDir(), "mappings.yaml"))
+	assert.NoError(t, err)
+	assert.NoError(t, mappings.Set(mapping.Mappings{Imdb: map[string]string{"tt0000001": mapping.Ignore}}))
+
+	var tests = []struct {
+		name     string
+		event    Event
+		expected string
+	}{
+		{"ignored by mapping", Event{Film: Film{ImdbId: "tt0000001"}, Action: FilmLogged}, SkipIgnoredByMapping},
+		{"no film ID", Event{Film: Film{Title: "The Matrix"}, Action: FilmLogged}, SkipNoFilmId},
+		{"likes disabled", Event{Film: Film{ImdbId: "tt0133093"}, Action: FilmLiked}, SkipLikesDisabled},
+		{"unknown action", Event{Film: Film{ImdbId: "tt0133093"}, Action: Action(42)}, SkipUnknownAction},
+	}
+
+	// Ignored events are reported as skipped without going to Letterboxd, so
+	// every event handed to a worker has an outcome
+	var worker = Worker{options: WorkerOptions{Mappings: mappings}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actionStr, skipped, err = worker.process(test.event, map[string]interface{}{})
+			assert.Empty(t, actionStr)
+			assert.Equal(t, test.expected, skipped)
+			assert.NoError(t, err)
+		})
+	}
+}
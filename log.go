@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"emboxd/api"
+	"emboxd/config"
+)
+
+// runLog implements `emboxd log --user <letterboxd username> <imdb id>`,
+// queueing a film watched outside the media servers on a running instance,
+// and returns the exit code
+func runLog(args []string) int {
+	var flags = flag.NewFlagSet("log", flag.ExitOnError)
+	var film api.ManualFilm
+	var username string
+	var serverURL string
+	var token string
+	var configFilename string
+	flags.StringVar(&username, "user", "", "Letterboxd username to log the film for")
+	flags.StringVar(&film.Action, "action", "log", "What to do on Letterboxd: watched, log or unwatch")
+	flags.StringVar(&film.Date, "date", "", "Date watched as YYYY-MM-DD (default today)")
+	flags.Float64Var(&film.Rating, "rating", 0, "Rating in stars from 0.5 to 5, only with --action log")
+	flags.StringVar(&film.Title, "title", "", "Title to check the Letterboxd film page against")
+	flags.IntVar(&film.Year, "year", 0, "Release year to check the Letterboxd film page against")
+	flags.StringVar(&serverURL, "url", "", "URL of the running EmBoxd (default http://localhost:$PORT)")
+	flags.StringVar(&token, "token", "", "Admin API token (default $ADMIN_TOKEN, or admin.token in the configuration)")
+	flags.StringVar(&configFilename, "c", "config/config.yaml", "Path to configuration file, for the admin token")
+	flags.StringVar(&configFilename, "config", "config/config.yaml", "Path to configuration file, for the admin token")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: emboxd log --user <letterboxd username> [options] <imdb id>")
+		flags.PrintDefaults()
+	}
+
+	// Allow options after the IMDb ID, e.g. `emboxd log --user X tt0133093 --date 2026-10-01`
+	var positional []string
+	for flags.Parse(args); flags.NArg() > 0; flags.Parse(args) {
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != 1 || username == "" {
+		flags.Usage()
+		return 2
+	}
+	film.ImdbId = positional[0]
+
+	if serverURL == "" {
+		serverURL = os.Getenv("EMBOXD_URL")
+	}
+	if serverURL == "" {
+		var port = os.Getenv("PORT")
+		if port == "" {
+			port = "9001"
+		}
+		serverURL = "http://localhost:" + port
+	}
+	if token == "" {
+		token = os.Getenv("ADMIN_TOKEN")
+	}
+	if token == "" {
+		if _, err := os.Stat(configFilename); err == nil {
+			token = config.Load(configFilename).Admin.Token
+		}
+	}
+
+	var body, _ = json.Marshal(film)
+	var endpoint = strings.TrimSuffix(serverURL, "/") + "/admin/users/" + url.PathEscape(username) + "/films"
+	var request, requestErr = http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if requestErr != nil {
+		fmt.Fprintln(os.Stderr, requestErr)
+		return 1
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	var client = http.Client{Timeout: 30 * time.Second}
+	var response, responseErr = client.Do(request)
+	if responseErr != nil {
+		fmt.Fprintln(os.Stderr, responseErr)
+		return 1
+	}
+	defer response.Body.Close()
+	var responseBody, _ = io.ReadAll(response.Body)
+
+	switch response.StatusCode {
+	case http.StatusAccepted:
+		var queued struct {
+			Action string `json:"action"`
+			Date   string `json:"date"`
+		}
+		json.Unmarshal(responseBody, &queued)
+		fmt.Printf("Queued %s of %s for %s on %s\n", queued.Action, film.ImdbId, username, queued.Date)
+		return 0
+	case http.StatusNotFound:
+		fmt.Fprintf(os.Stderr, "No Letterboxd account %q is configured\n", username)
+	default:
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(responseBody, &failure) == nil && failure.Error != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", response.Status, failure.Error)
+		} else {
+			fmt.Fprintln(os.Stderr, response.Status)
+		}
+	}
+	return 1
+}
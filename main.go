@@ -4,22 +4,43 @@ import (
 	"flag"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strconv"
+	"time"
 
 	"emboxd/api"
+	"emboxd/capture"
 	"emboxd/config"
+	"emboxd/diarysync"
+	"emboxd/history"
 	"emboxd/letterboxd"
 	"emboxd/logging"
+	"emboxd/mapping"
+	"emboxd/mediaserver"
+	"emboxd/mqtt"
 	"emboxd/notification"
+	"emboxd/notifier"
+	"emboxd/polling"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "log" {
+		os.Exit(runLog(os.Args[2:]))
+	}
+
 	var verbose bool
 	var configFilename string
 	var historySize int
 	var logDir string
 	var logJson bool
 	var port string
+	var healthInterval time.Duration
+	var dataDir string
+	var dryRun bool
+	var recordWebhooks bool
 
 	// Command-line flags
 	flag.BoolVar(&verbose, "v", false, "Enable debug logging")
@@ -30,6 +51,10 @@ func main() {
 	flag.StringVar(&logDir, "log-dir", "", "Directory for log files (empty for stdout only)")
 	flag.BoolVar(&logJson, "log-json", false, "Output logs in JSON format")
 	flag.StringVar(&port, "port", "9001", "Port to listen on")
+	flag.StringVar(&dataDir, "data-dir", "data", "Directory for application data such as mappings.yaml")
+	flag.BoolVar(&dryRun, "dry-run", false, "Verify and record Letterboxd actions without performing them")
+	flag.BoolVar(&recordWebhooks, "record-webhooks", false, "Record raw webhook requests to webhooks.jsonl in the data directory")
+	flag.DurationVar(&healthInterval, "health-interval", 5*time.Minute, "Interval between background Letterboxd health probes")
 	flag.Parse()
 
 	// Environment variable overrides
@@ -55,6 +80,24 @@ func main() {
 		port = envPort
 	}
 
+	if envDataDir := os.Getenv("DATA_DIR"); envDataDir != "" {
+		dataDir = envDataDir
+	}
+
+	if envDryRun := os.Getenv("DRY_RUN"); envDryRun != "" {
+		dryRun = envDryRun == "true" || envDryRun == "1" || envDryRun == "yes"
+	}
+
+	if envRecord := os.Getenv("RECORD_WEBHOOKS"); envRecord != "" {
+		recordWebhooks = envRecord == "true" || envRecord == "1" || envRecord == "yes"
+	}
+
+	if envInterval := os.Getenv("HEALTH_INTERVAL"); envInterval != "" {
+		if interval, err := time.ParseDuration(envInterval); err == nil && interval > 0 {
+			healthInterval = interval
+		}
+	}
+
 	// Configure enhanced logging
 	logConfig := logging.DefaultLogConfig(verbose)
 	if logDir != "" {
@@ -68,20 +111,163 @@ func main() {
 	}
 	var conf = config.Load(configFilename)
 
+	var mappings, mappingsErr = mapping.NewStore(filepath.Join(dataDir, "mappings.yaml"))
+	if mappingsErr != nil {
+		slog.Error("Failed to load mappings", slog.String("error", mappingsErr.Error()))
+		os.Exit(1)
+	}
+
+	if err := letterboxd.Launch(); err != nil {
+		slog.Error("Failed to launch browser", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	var eventHistory = history.NewStore(historySize)
+
+	var emby *mediaserver.Emby
+	if conf.Servers.Emby.URL != "" {
+		emby = mediaserver.NewEmby(conf.Servers.Emby.URL, conf.Servers.Emby.ApiKey)
+	}
+	var plex *mediaserver.Plex
+	if conf.Servers.Plex.URL != "" {
+		plex = mediaserver.NewPlex(conf.Servers.Plex.URL, conf.Servers.Plex.Token)
+	}
+	var notifierTargets []*notifier.Target
+	for _, target := range conf.Notifiers {
+		var notifierTarget, targetErr = notifier.NewTarget(notifier.TargetOptions{
+			Type:     target.Type,
+			URL:      target.URL,
+			Token:    target.Token,
+			Headers:  target.Headers,
+			Users:    target.Users,
+			Events:   target.Events,
+			Title:    target.Title,
+			Template: target.Template,
+		})
+		if targetErr != nil {
+			slog.Error("Invalid notifier configuration", slog.String("error", targetErr.Error()))
+			os.Exit(1)
+		}
+		notifierTargets = append(notifierTargets, notifierTarget)
+	}
+	var notifications = notifier.New(notifierTargets)
+
+	var mqttPublisher *mqtt.Publisher
+	if conf.MQTT.Broker != "" {
+		var publisherErr error
+		mqttPublisher, publisherErr = mqtt.New(mqtt.Options{
+			Broker:          conf.MQTT.Broker,
+			Username:        conf.MQTT.Username,
+			Password:        conf.MQTT.Password,
+			ClientID:        conf.MQTT.ClientID,
+			TopicPrefix:     conf.MQTT.TopicPrefix,
+			Discovery:       conf.MQTT.Discovery == nil || *conf.MQTT.Discovery,
+			DiscoveryPrefix: conf.MQTT.DiscoveryPrefix,
+		})
+		if publisherErr != nil {
+			slog.Error("Invalid MQTT configuration", slog.String("error", publisherErr.Error()))
+			os.Exit(1)
+		}
+	}
+
+	var sessionMaxAge = notification.DefaultSessionMaxAge
+	if conf.Sessions.MaxAge != nil {
+		sessionMaxAge = *conf.Sessions.MaxAge
+	}
+
+	var syncGuard = diarysync.NewGuard()
+	var reverseSyncTargets []diarysync.Target
+
 	var notificationProcessorByEmbyUsername = make(map[string]*notification.Processor, len(conf.Users))
 	var notificationProcessorByPlexUsername = make(map[string]*notification.Processor, len(conf.Users))
 	var notificationProcessorByPlexAccountID = make(map[string]*notification.Processor, len(conf.Users))
 	var letterboxdWorkers = make(map[string]*letterboxd.Worker, len(conf.Users))
+	var adminUsers = make([]api.AdminUser, 0, len(conf.Users))
 	for _, user := range conf.Users {
 		var letterboxdWorker, workerExists = letterboxdWorkers[user.Letterboxd.Username]
 		if !workerExists {
-			var worker = letterboxd.NewWorker(user.Letterboxd.Username, user.Letterboxd.Password, user.Letterboxd.LogFilms)
+			var userDryRun = dryRun || conf.DryRun
+			if user.Letterboxd.DryRun != nil {
+				userDryRun = *user.Letterboxd.DryRun
+			}
+			if userDryRun {
+				slog.Info("Dry run enabled, Letterboxd will not be changed", slog.String("username", user.Letterboxd.Username))
+			}
+			var worker = letterboxd.NewWorker(user.Letterboxd.Username, user.Letterboxd.Password, letterboxd.WorkerOptions{
+				LogFilms:            user.Letterboxd.LogFilms,
+				DuplicateWindow:     duplicateWindow(user.Letterboxd.DuplicateWindow),
+				DryRun:              userDryRun,
+				RemoveFromWatchlist: user.Letterboxd.RemoveFromWatchlist,
+				SyncLikes:           user.Letterboxd.SyncLikes,
+				ListTemplate:        user.Letterboxd.ListTemplate,
+				DiaryTagsTemplate:   user.Letterboxd.DiaryTags,
+				DiaryReviewTemplate: user.Letterboxd.DiaryReview,
+				Mappings:            mappings,
+				OnOutcome: func(outcome letterboxd.Outcome) {
+					eventHistory.Add(history.FromOutcome(outcome))
+					syncGuard.RecordOutcome(outcome)
+					notifications.NotifyOutcome(outcome)
+					if mqttPublisher != nil {
+						mqttPublisher.PublishOutcome(outcome)
+					}
+				},
+				OnStatusChange: notifications.NotifyStatus,
+			})
 			worker.Start()
+			worker.StartProber(healthInterval)
 			letterboxdWorker = &worker
 			letterboxdWorkers[user.Letterboxd.Username] = letterboxdWorker
 		}
 
-		var notificationProcessor = notification.NewProcessor(letterboxdWorker.HandleEvent)
+		var adminUser = api.AdminUser{
+			Letterboxd:   user.Letterboxd.Username,
+			EmbyUsername: user.Emby.Username,
+			PlexUsername: user.Plex.Username,
+			PlexID:       user.Plex.ID,
+			PlexToken:    user.Plex.Token != "",
+			LogFilms:     user.Letterboxd.LogFilms,
+			SyncLikes:    user.Letterboxd.SyncLikes,
+			ReverseSync:  user.Letterboxd.ReverseSync,
+			DryRun:       dryRun || conf.DryRun,
+		}
+		if user.Letterboxd.DryRun != nil {
+			adminUser.DryRun = *user.Letterboxd.DryRun
+		}
+		adminUsers = append(adminUsers, adminUser)
+
+		if user.Letterboxd.ReverseSync {
+			var target = diarysync.Target{Username: user.Letterboxd.Username}
+			if emby != nil && user.Emby.Username != "" {
+				target.Libraries = append(target.Libraries, emby.Library(user.Emby.Username))
+			}
+			if plex != nil && user.Plex.Token != "" {
+				target.Libraries = append(target.Libraries, plex.WithToken(user.Plex.Token))
+			} else if plex != nil && (user.Plex.Username != "" || user.Plex.ID != "") {
+				// The server token would mark films played for the server owner instead
+				slog.Warn("Skipping Plex reverse sync without a plex.token for the user", slog.String("username", user.Letterboxd.Username))
+			}
+			if len(target.Libraries) == 0 {
+				slog.Warn("Reverse sync enabled without a configured media server", slog.String("username", user.Letterboxd.Username))
+			}
+			reverseSyncTargets = append(reverseSyncTargets, target)
+		}
+
+		var letterboxdUsername = user.Letterboxd.Username
+		var notificationProcessor = notification.NewProcessor(func(event letterboxd.Event) {
+			if syncGuard.IsEcho(letterboxdUsername, event) {
+				slog.Info("Ignoring event for film marked played from Letterboxd diary",
+					slog.String("username", letterboxdUsername),
+					slog.String("imdbId", event.ImdbId))
+				return
+			}
+			letterboxdWorker.HandleEvent(event)
+		})
+		if sessionMaxAge > 0 {
+			notificationProcessor.StartExpiry(sessionMaxAge)
+		}
+		if mqttPublisher != nil {
+			mqttPublisher.AddUser(user.Letterboxd.Username, letterboxdWorker.Status, &notificationProcessor)
+		}
 		if user.Emby.Username != "" {
 			notificationProcessorByEmbyUsername[user.Emby.Username] = &notificationProcessor
 		}
@@ -93,13 +279,79 @@ func main() {
 		}
 	}
 
+	if len(reverseSyncTargets) > 0 {
+		var poller = diarysync.NewPoller(letterboxd.NewDiaryFeed(), syncGuard, reverseSyncTargets, conf.ReverseSync.Lookback)
+		poller.OnResult = func(result diarysync.Result) {
+			eventHistory.Add(history.FromReverseSync(result))
+		}
+		var interval = conf.ReverseSync.Interval
+		if interval <= 0 {
+			interval = 15 * time.Minute
+		}
+		poller.Start(interval)
+	}
+
+	if plex != nil && conf.Servers.Plex.PollInterval > 0 {
+		var tokensByAccountID = make(map[string]string)
+		for _, user := range conf.Users {
+			if user.Plex.ID != "" && user.Plex.Token != "" {
+				tokensByAccountID[user.Plex.ID] = user.Plex.Token
+			}
+		}
+		polling.NewPlexPoller(
+			plex,
+			tokensByAccountID,
+			notificationProcessorByPlexAccountID,
+			notificationProcessorByPlexUsername,
+			eventHistory,
+		).Start(conf.Servers.Plex.PollInterval)
+	}
+
+	if emby != nil && conf.Servers.Emby.PollInterval > 0 {
+		polling.NewEmbyPoller(
+			emby,
+			notificationProcessorByEmbyUsername,
+			eventHistory,
+			filepath.Join(dataDir, "emby-poll.json"),
+		).Start(conf.Servers.Emby.PollInterval)
+	}
+
+	if mqttPublisher != nil {
+		if err := mqttPublisher.Connect(); err != nil {
+			slog.Warn("Failed to connect to MQTT broker", slog.String("error", err.Error()))
+		}
+		defer mqttPublisher.Close()
+		var interval = conf.MQTT.Interval
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		mqttPublisher.Start(eventHistory, interval)
+	}
+
 	var app = api.New(
 		notificationProcessorByEmbyUsername,
 		notificationProcessorByPlexUsername,
 		notificationProcessorByPlexAccountID,
 		letterboxdWorkers,
-		historySize,
+		mappings,
+		eventHistory,
 	)
+	app.SetUsers(adminUsers)
+	if conf.Admin.Token != "" {
+		app.RequireAdminToken(conf.Admin.Token)
+	}
+	if conf.Dashboard.Username != "" && conf.Dashboard.Password != "" {
+		app.RequireDashboardLogin(conf.Dashboard.Username, conf.Dashboard.Password)
+	}
+	if recordWebhooks {
+		var recorder, recorderErr = capture.NewRecorder(filepath.Join(dataDir, "webhooks.jsonl"))
+		if recorderErr != nil {
+			slog.Error("Failed to open webhook recording", slog.String("error", recorderErr.Error()))
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		app.RecordWebhooks(recorder)
+	}
 
 	// Use graceful shutdown server
 	handler := app.Handler()
@@ -110,3 +362,11 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// duplicateWindow returns a user's configured duplicate window, or the default
+func duplicateWindow(configured *time.Duration) time.Duration {
+	if configured != nil {
+		return *configured
+	}
+	return letterboxd.DefaultDuplicateWindow
+}
@@ -0,0 +1,166 @@
+package mapping
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Ignore is the mapping target for films that should never be synced
+const Ignore = "ignore"
+
+// Letterboxd film slugs as they appear in /film/<slug>/
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Mappings maps media identifiers to a Letterboxd film slug or Ignore
+type Mappings struct {
+	Imdb map[string]string `yaml:"imdb,omitempty" json:"imdb"`
+	Tmdb map[string]string `yaml:"tmdb,omitempty" json:"tmdb"`
+	Emby map[string]string `yaml:"emby,omitempty" json:"emby"`
+	Plex map[string]string `yaml:"plex,omitempty" json:"plex"`
+}
+
+// Ids holds the identifiers a film can be looked up by
+type Ids struct {
+	Imdb   string
+	Tmdb   string
+	Server string // "emby" or "plex"
+	Item   string // Emby item ID or Plex rating key
+}
+
+// Validate checks that every target is a Letterboxd slug or Ignore
+func (m Mappings) Validate() error {
+	for name, table := range map[string]map[string]string{"imdb": m.Imdb, "tmdb": m.Tmdb, "emby": m.Emby, "plex": m.Plex} {
+		for id, target := range table {
+			if id == "" {
+				return fmt.Errorf("%s: empty id", name)
+			}
+			if target != Ignore && !slugPattern.MatchString(target) {
+				return fmt.Errorf("%s: %s: invalid Letterboxd slug %q", name, id, target)
+			}
+		}
+	}
+	return nil
+}
+
+func (m Mappings) clone() Mappings {
+	var clone = Mappings{
+		Imdb: maps.Clone(m.Imdb),
+		Tmdb: maps.Clone(m.Tmdb),
+		Emby: maps.Clone(m.Emby),
+		Plex: maps.Clone(m.Plex),
+	}
+	for _, table := range []*map[string]string{&clone.Imdb, &clone.Tmdb, &clone.Emby, &clone.Plex} {
+		if *table == nil {
+			*table = make(map[string]string)
+		}
+	}
+	return clone
+}
+
+// Store is a thread-safe mapping table persisted to a YAML file
+type Store struct {
+	sync.RWMutex
+	filename string
+	mappings Mappings
+}
+
+// NewStore loads the mapping table from filename; a missing file yields an empty table
+func NewStore(filename string) (*Store, error) {
+	var store = &Store{
+		filename: filename,
+		mappings: Mappings{}.clone(),
+	}
+
+	var data, readErr = os.ReadFile(filename)
+	if os.IsNotExist(readErr) {
+		return store, nil
+	} else if readErr != nil {
+		return nil, readErr
+	}
+
+	var mappings Mappings
+	if err := yaml.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	if err := mappings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mapping in %s: %w", filename, err)
+	}
+	store.mappings = mappings.clone()
+	return store, nil
+}
+
+// Get returns a copy of the mapping table
+func (s *Store) Get() Mappings {
+	s.RLock()
+	defer s.RUnlock()
+	return s.mappings.clone()
+}
+
+// Set validates and replaces the mapping table, writing it back to disk
+func (s *Store) Set(mappings Mappings) error {
+	if err := mappings.Validate(); err != nil {
+		return err
+	}
+
+	var data, err = yaml.Marshal(mappings)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// Write to a temporary file first so a failed write can't corrupt the table
+	if err := os.MkdirAll(filepath.Dir(s.filename), 0755); err != nil {
+		return err
+	}
+	var tmpFilename = s.filename + ".tmp"
+	if err := os.WriteFile(tmpFilename, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFilename, s.filename); err != nil {
+		return err
+	}
+
+	s.mappings = mappings.clone()
+	return nil
+}
+
+// Resolve returns the Letterboxd slug (or Ignore) for a film, checking the
+// media server item ID first, then TMDb, then IMDb
+func (s *Store) Resolve(ids Ids) (string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var itemTable map[string]string
+	switch ids.Server {
+	case "emby":
+		itemTable = s.mappings.Emby
+	case "plex":
+		itemTable = s.mappings.Plex
+	}
+
+	var candidates = []struct {
+		table map[string]string
+		id    string
+	}{
+		{itemTable, ids.Item},
+		{s.mappings.Tmdb, ids.Tmdb},
+		{s.mappings.Imdb, ids.Imdb},
+	}
+	for _, candidate := range candidates {
+		if candidate.id == "" {
+			continue
+		}
+		if target, ok := candidate.table[candidate.id]; ok {
+			return target, true
+		}
+	}
+	return "", false
+}
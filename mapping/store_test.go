@@ -0,0 +1,65 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	var filename = filepath.Join(t.TempDir(), "mappings.yaml")
+
+	// Missing file yields an empty table
+	store, err := NewStore(filename)
+	assert.NoError(t, err)
+	_, ok := store.Resolve(Ids{Imdb: "tt0133093"})
+	assert.False(t, ok)
+
+	err = store.Set(Mappings{
+		Imdb: map[string]string{"tt0133093": "the-matrix", "tt0000001": Ignore},
+		Tmdb: map[string]string{"27205": "inception"},
+		Plex: map[string]string{"42": "blade-runner-the-final-cut"},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		ids      Ids
+		expected string
+		found    bool
+	}{
+		{"IMDb mapping", Ids{Imdb: "tt0133093"}, "the-matrix", true},
+		{"Ignored film", Ids{Imdb: "tt0000001"}, Ignore, true},
+		{"TMDb mapping", Ids{Tmdb: "27205"}, "inception", true},
+		{"Item ID takes precedence", Ids{Imdb: "tt0133093", Server: "plex", Item: "42"}, "blade-runner-the-final-cut", true},
+		{"Item ID is per server", Ids{Server: "emby", Item: "42"}, "", false},
+		{"Unmapped film", Ids{Imdb: "tt9999999"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, ok := store.Resolve(tt.ids)
+			assert.Equal(t, tt.found, ok)
+			assert.Equal(t, tt.expected, target)
+		})
+	}
+
+	// Table survives a reload from disk
+	reloaded, err := NewStore(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, store.Get(), reloaded.Get())
+}
+
+func TestStoreRejectsInvalidSlug(t *testing.T) {
+	var filename = filepath.Join(t.TempDir(), "mappings.yaml")
+	store, err := NewStore(filename)
+	assert.NoError(t, err)
+
+	err = store.Set(Mappings{Imdb: map[string]string{"tt0133093": "https://letterboxd.com/film/the-matrix/"}})
+	assert.Error(t, err)
+
+	// Nothing is written for a rejected table
+	_, statErr := os.Stat(filename)
+	assert.True(t, os.IsNotExist(statErr))
+}
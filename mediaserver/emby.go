@@ -0,0 +1,283 @@
+package mediaserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Emby dates as accepted by the PlayedItems endpoint
+const _EMBY_DATE_PLAYED_LAYOUT string = "20060102150405"
+
+// Max recently played items fetched per user
+const _EMBY_PLAYED_LIMIT int = 100
+
+// Emby is a client for the Emby (or Jellyfin) REST API using an API key
+type Emby struct {
+	url    string
+	apiKey string
+	client *http.Client
+
+	lock      sync.Mutex
+	userIdsBy map[string]string // User ID by username
+}
+
+func NewEmby(serverURL string, apiKey string) *Emby {
+	return &Emby{
+		url:       strings.TrimSuffix(serverURL, "/"),
+		apiKey:    apiKey,
+		client:    &http.Client{Timeout: _REQUEST_TIMEOUT},
+		userIdsBy: make(map[string]string),
+	}
+}
+
+func (e *Emby) request(method string, path string, query url.Values) ([]byte, error) {
+	var request, err = http.NewRequest(method, e.url+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Emby-Token", e.apiKey)
+	request.Header.Set("Accept", "application/json")
+	return do(e.client, request)
+}
+
+// UserId returns the ID of the Emby user with the given name
+func (e *Emby) UserId(username string) (string, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if id, ok := e.userIdsBy[username]; ok {
+		return id, nil
+	}
+
+	var body, err = e.request(http.MethodGet, "/Users", url.Values{})
+	if err != nil {
+		return "", err
+	}
+	var users []struct {
+		Id   string `json:"Id"`
+		Name string `json:"Name"`
+	}
+	if err := json.Unmarshal(body, &users); err != nil {
+		return "", fmt.Errorf("failed to parse Emby users: %w", err)
+	}
+	for _, user := range users {
+		e.userIdsBy[user.Name] = user.Id
+	}
+
+	var id, ok = e.userIdsBy[username]
+	if !ok {
+		return "", fmt.Errorf("no Emby user named %q", username)
+	}
+	return id, nil
+}
+
+// EmbyItem is a library item with the watch state of a user
+type EmbyItem struct {
+	Movie
+	Type       string
+	Runtime    time.Duration
+	Height     int
+	LastPlayed time.Time
+}
+
+// EmbySession is an item being played on the server
+type EmbySession struct {
+	Id         string
+	UserId     string
+	Username   string
+	Client     string
+	DeviceName string
+	Item       EmbyItem
+	Position   time.Duration
+	Paused     bool
+}
+
+type embyItem struct {
+	Id             string            `json:"Id"`
+	Name           string            `json:"Name"`
+	Type           string            `json:"Type"`
+	ProductionYear int               `json:"ProductionYear"`
+	RunTimeTicks   int64             `json:"RunTimeTicks"`
+	Height         int               `json:"Height"`
+	ProviderIds    map[string]string `json:"ProviderIds"`
+	UserData       struct {
+		Played         bool   `json:"Played"`
+		LastPlayedDate string `json:"LastPlayedDate"`
+	} `json:"UserData"`
+}
+
+func (i embyItem) item() EmbyItem {
+	var lastPlayed, _ = time.Parse(time.RFC3339, i.UserData.LastPlayedDate)
+	return EmbyItem{
+		Movie: Movie{
+			ItemId: i.Id,
+			Title:  i.Name,
+			Year:   i.ProductionYear,
+			ImdbId: providerId(i.ProviderIds, "Imdb"),
+			TmdbId: providerId(i.ProviderIds, "Tmdb"),
+			Played: i.UserData.Played,
+		},
+		Type:       i.Type,
+		Runtime:    ticksToDuration(i.RunTimeTicks),
+		Height:     i.Height,
+		LastPlayed: lastPlayed,
+	}
+}
+
+// ticksToDuration converts Emby's 100ns ticks to a duration
+func ticksToDuration(ticks int64) time.Duration {
+	return time.Duration(ticks * 100)
+}
+
+// providerId looks up a provider ID regardless of key case, which differs
+// between Emby and Jellyfin
+func providerId(ids map[string]string, provider string) string {
+	for key, id := range ids {
+		if strings.EqualFold(key, provider) {
+			return id
+		}
+	}
+	return ""
+}
+
+// Movies returns every movie in the user's libraries
+func (e *Emby) Movies(userId string) ([]Movie, error) {
+	var body, err = e.request(http.MethodGet, "/Users/"+userId+"/Items", url.Values{
+		"Recursive":        {"true"},
+		"IncludeItemTypes": {"Movie"},
+		"Fields":           {"ProviderIds,ProductionYear"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Items []embyItem `json:"Items"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Emby items: %w", err)
+	}
+
+	var movies = make([]Movie, 0, len(result.Items))
+	for _, item := range result.Items {
+		movies = append(movies, item.item().Movie)
+	}
+	return movies, nil
+}
+
+// PlayedSince returns the user's movies last played after the given time, newest first
+func (e *Emby) PlayedSince(userId string, since time.Time) ([]EmbyItem, error) {
+	var body, err = e.request(http.MethodGet, "/Users/"+userId+"/Items", url.Values{
+		"Recursive":        {"true"},
+		"IncludeItemTypes": {"Movie"},
+		"IsPlayed":         {"true"},
+		"SortBy":           {"DatePlayed"},
+		"SortOrder":        {"Descending"},
+		"Limit":            {strconv.Itoa(_EMBY_PLAYED_LIMIT)},
+		"Fields":           {"ProviderIds,ProductionYear"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Items []embyItem `json:"Items"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Emby items: %w", err)
+	}
+
+	var items []EmbyItem
+	for _, embyItem := range result.Items {
+		var item = embyItem.item()
+		if item.LastPlayed.After(since) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// Sessions returns the server's sessions that are playing something
+func (e *Emby) Sessions() ([]EmbySession, error) {
+	var body, err = e.request(http.MethodGet, "/Sessions", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		Id             string    `json:"Id"`
+		UserId         string    `json:"UserId"`
+		UserName       string    `json:"UserName"`
+		Client         string    `json:"Client"`
+		DeviceName     string    `json:"DeviceName"`
+		NowPlayingItem *embyItem `json:"NowPlayingItem"`
+		PlayState      struct {
+			PositionTicks int64 `json:"PositionTicks"`
+			IsPaused      bool  `json:"IsPaused"`
+		} `json:"PlayState"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Emby sessions: %w", err)
+	}
+
+	var sessions []EmbySession
+	for _, session := range result {
+		if session.NowPlayingItem == nil {
+			continue
+		}
+		sessions = append(sessions, EmbySession{
+			Id:         session.Id,
+			UserId:     session.UserId,
+			Username:   session.UserName,
+			Client:     session.Client,
+			DeviceName: session.DeviceName,
+			Item:       session.NowPlayingItem.item(),
+			Position:   ticksToDuration(session.PlayState.PositionTicks),
+			Paused:     session.PlayState.IsPaused,
+		})
+	}
+	return sessions, nil
+}
+
+// MarkPlayed marks an item played for the user at the given time
+func (e *Emby) MarkPlayed(userId string, itemId string, at time.Time) error {
+	var _, err = e.request(http.MethodPost, "/Users/"+userId+"/PlayedItems/"+itemId, url.Values{
+		"DatePlayed": {at.UTC().Format(_EMBY_DATE_PLAYED_LAYOUT)},
+	})
+	return err
+}
+
+// Library returns the Emby library as seen by the named user
+func (e *Emby) Library(username string) Library {
+	return embyLibrary{emby: e, username: username}
+}
+
+type embyLibrary struct {
+	emby     *Emby
+	username string
+}
+
+func (l embyLibrary) Server() string {
+	return "emby"
+}
+
+func (l embyLibrary) Movies() ([]Movie, error) {
+	var userId, err = l.emby.UserId(l.username)
+	if err != nil {
+		return nil, err
+	}
+	return l.emby.Movies(userId)
+}
+
+func (l embyLibrary) MarkPlayed(movie Movie, at time.Time) error {
+	var userId, err = l.emby.UserId(l.username)
+	if err != nil {
+		return err
+	}
+	return l.emby.MarkPlayed(userId, movie.ItemId, at)
+}
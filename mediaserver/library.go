@@ -0,0 +1,65 @@
+package mediaserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const _REQUEST_TIMEOUT time.Duration = 30 * time.Second
+
+// Movie is a film in a media server library, as seen by one user
+type Movie struct {
+	ItemId string // Emby item ID or Plex rating key
+	Title  string
+	Year   int
+	ImdbId string
+	TmdbId string
+	Played bool
+}
+
+// Library is one user's view of a media server's movies
+type Library interface {
+	// Server returns the media server name, "emby" or "plex"
+	Server() string
+	Movies() ([]Movie, error)
+	MarkPlayed(movie Movie, at time.Time) error
+}
+
+// FindMovie returns the movie with the IMDb ID, or failing that the TMDb ID
+func FindMovie(movies []Movie, imdbId string, tmdbId string) (Movie, bool) {
+	if imdbId != "" {
+		for _, movie := range movies {
+			if movie.ImdbId == imdbId {
+				return movie, true
+			}
+		}
+	}
+	if tmdbId != "" {
+		for _, movie := range movies {
+			if movie.TmdbId == tmdbId {
+				return movie, true
+			}
+		}
+	}
+	return Movie{}, false
+}
+
+// do sends a request and returns the response body, failing on non-2xx statuses
+func do(client *http.Client, request *http.Request) ([]byte, error) {
+	var response, err = client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var body, readErr = io.ReadAll(response.Body)
+	if readErr != nil {
+		return nil, readErr
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: unexpected status %d", request.Method, request.URL.Path, response.StatusCode)
+	}
+	return body, nil
+}
@@ -0,0 +1,239 @@
+package mediaserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Plex movie library type for /library/all
+const _PLEX_MOVIE_TYPE string = "1"
+
+// Plex is a client for a Plex Media Server, acting as the user the token belongs to
+type Plex struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewPlex(serverURL string, token string) *Plex {
+	return &Plex{
+		url:    strings.TrimSuffix(serverURL, "/"),
+		token:  token,
+		client: &http.Client{Timeout: _REQUEST_TIMEOUT},
+	}
+}
+
+// WithToken returns a client for the same server acting as another user
+func (p *Plex) WithToken(token string) *Plex {
+	return &Plex{
+		url:    p.url,
+		token:  token,
+		client: p.client,
+	}
+}
+
+func (p *Plex) request(method string, path string, query url.Values) ([]byte, error) {
+	var request, err = http.NewRequest(method, p.url+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Plex-Token", p.token)
+	request.Header.Set("Accept", "application/json")
+	return do(p.client, request)
+}
+
+// PlexItem is a library item with the watch state of the token's user
+type PlexItem struct {
+	Movie
+	Type         string
+	Duration     time.Duration
+	ViewOffset   time.Duration
+	ViewCount    int
+	LastViewedAt time.Time
+	Library      string
+	// Video resolution as reported by Plex, e.g. "1080" or "4k"
+	VideoResolution string
+}
+
+// PlexSession is an item being played on the server
+type PlexSession struct {
+	PlexItem
+	Id        string
+	AccountId string
+	Username  string
+	State     string // "playing", "paused" or "buffering"
+	Player    string // Client application, e.g. "Plex for LG"
+	Device    string // Player name, e.g. "Living Room"
+}
+
+type plexMediaContainer struct {
+	MediaContainer struct {
+		Metadata []plexMetadata `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+type plexMetadata struct {
+	RatingKey           string `json:"ratingKey"`
+	SessionKey          string `json:"sessionKey"`
+	Type                string `json:"type"`
+	Title               string `json:"title"`
+	Year                int    `json:"year"`
+	Duration            int64  `json:"duration"`
+	ViewOffset          int64  `json:"viewOffset"`
+	ViewCount           int    `json:"viewCount"`
+	LastViewedAt        int64  `json:"lastViewedAt"`
+	LibrarySectionTitle string `json:"librarySectionTitle"`
+	Guid                []struct {
+		Id string `json:"id"`
+	} `json:"Guid"`
+	Media []struct {
+		VideoResolution string `json:"videoResolution"`
+	} `json:"Media"`
+	User struct {
+		Id    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"User"`
+	Player struct {
+		State   string `json:"state"`
+		Title   string `json:"title"`
+		Product string `json:"product"`
+	} `json:"Player"`
+	Session struct {
+		Id string `json:"id"`
+	} `json:"Session"`
+}
+
+func (m plexMetadata) movie() Movie {
+	var movie = Movie{
+		ItemId: m.RatingKey,
+		Title:  m.Title,
+		Year:   m.Year,
+		Played: m.ViewCount > 0,
+	}
+	for _, guid := range m.Guid {
+		if id := plexGuidId(guid.Id, "imdb"); id != "" {
+			movie.ImdbId = id
+		}
+		if id := plexGuidId(guid.Id, "tmdb"); id != "" {
+			movie.TmdbId = id
+		}
+	}
+	return movie
+}
+
+func (m plexMetadata) item() PlexItem {
+	var item = PlexItem{
+		Movie:      m.movie(),
+		Type:       m.Type,
+		Duration:   time.Duration(m.Duration) * time.Millisecond,
+		ViewOffset: time.Duration(m.ViewOffset) * time.Millisecond,
+		ViewCount:  m.ViewCount,
+		Library:    m.LibrarySectionTitle,
+	}
+	if m.LastViewedAt > 0 {
+		item.LastViewedAt = time.Unix(m.LastViewedAt, 0)
+	}
+	if len(m.Media) > 0 {
+		item.VideoResolution = m.Media[0].VideoResolution
+	}
+	return item
+}
+
+// plexGuidId returns the ID from a GUID such as "imdb://tt0133093"
+func plexGuidId(guid string, agent string) string {
+	var prefix = agent + "://"
+	if strings.HasPrefix(guid, prefix) {
+		return strings.TrimPrefix(guid, prefix)
+	}
+	return ""
+}
+
+func (p *Plex) Server() string {
+	return "plex"
+}
+
+// Movies returns every movie on the server
+func (p *Plex) Movies() ([]Movie, error) {
+	var body, err = p.request(http.MethodGet, "/library/all", url.Values{
+		"type":         {_PLEX_MOVIE_TYPE},
+		"includeGuids": {"1"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result plexMediaContainer
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Plex library: %w", err)
+	}
+
+	var movies = make([]Movie, 0, len(result.MediaContainer.Metadata))
+	for _, item := range result.MediaContainer.Metadata {
+		movies = append(movies, item.movie())
+	}
+	return movies, nil
+}
+
+// Item returns a library item by rating key, with the watch state of the token's user
+func (p *Plex) Item(ratingKey string) (PlexItem, error) {
+	var body, err = p.request(http.MethodGet, "/library/metadata/"+ratingKey, url.Values{
+		"includeGuids": {"1"},
+	})
+	if err != nil {
+		return PlexItem{}, err
+	}
+
+	var result plexMediaContainer
+	if err := json.Unmarshal(body, &result); err != nil {
+		return PlexItem{}, fmt.Errorf("failed to parse Plex metadata: %w", err)
+	}
+	if len(result.MediaContainer.Metadata) == 0 {
+		return PlexItem{}, fmt.Errorf("no Plex item with rating key %s", ratingKey)
+	}
+	return result.MediaContainer.Metadata[0].item(), nil
+}
+
+// Sessions returns the server's current playback sessions
+func (p *Plex) Sessions() ([]PlexSession, error) {
+	var body, err = p.request(http.MethodGet, "/status/sessions", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result plexMediaContainer
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Plex sessions: %w", err)
+	}
+
+	var sessions = make([]PlexSession, 0, len(result.MediaContainer.Metadata))
+	for _, metadata := range result.MediaContainer.Metadata {
+		var session = PlexSession{
+			PlexItem:  metadata.item(),
+			Id:        metadata.Session.Id,
+			AccountId: metadata.User.Id,
+			Username:  metadata.User.Title,
+			State:     metadata.Player.State,
+			Player:    metadata.Player.Product,
+			Device:    metadata.Player.Title,
+		}
+		if session.Id == "" {
+			// Older servers only identify sessions by their sessionKey
+			session.Id = metadata.SessionKey
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// MarkPlayed scrobbles the movie. Plex always uses the current time.
+func (p *Plex) MarkPlayed(movie Movie, at time.Time) error {
+	var _, err = p.request(http.MethodGet, "/:/scrobble", url.Values{
+		"identifier": {"com.plexapp.plugins.library"},
+		"key":        {movie.ItemId},
+	})
+	return err
+}
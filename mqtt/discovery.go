@@ -0,0 +1,126 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+var nonIdCharacters = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// discoveryDevice groups a user's entities in Home Assistant
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+// discoveryConfig registers an entity with Home Assistant, see
+// https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery
+type discoveryConfig struct {
+	Name                string          `json:"name"`
+	UniqueId            string          `json:"unique_id"`
+	StateTopic          string          `json:"state_topic"`
+	ValueTemplate       string          `json:"value_template"`
+	JsonAttributesTopic string          `json:"json_attributes_topic,omitempty"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	DeviceClass         string          `json:"device_class,omitempty"`
+	UnitOfMeasurement   string          `json:"unit_of_measurement,omitempty"`
+	Icon                string          `json:"icon,omitempty"`
+	Device              discoveryDevice `json:"device"`
+}
+
+// discoveryEntity is an entity registered for every user
+type discoveryEntity struct {
+	component string // Home Assistant integration, e.g. "sensor"
+	objectId  string
+	config    discoveryConfig
+	topic     string // User topic the entity's state is read from
+}
+
+var discoveryEntities = []discoveryEntity{
+	{
+		component: "sensor",
+		objectId:  "now_watching",
+		topic:     "watching",
+		config: discoveryConfig{
+			Name:          "Now watching",
+			ValueTemplate: "{{ value_json.film if value_json.state != 'idle' else 'Nothing' }}",
+			Icon:          "mdi:movie-open-play",
+		},
+	},
+	{
+		component: "sensor",
+		objectId:  "progress",
+		topic:     "watching",
+		config: discoveryConfig{
+			Name:              "Watching progress",
+			ValueTemplate:     "{{ value_json.progress }}",
+			UnitOfMeasurement: "%",
+			Icon:              "mdi:progress-clock",
+		},
+	},
+	{
+		component: "sensor",
+		objectId:  "last_logged",
+		topic:     "last_logged",
+		config: discoveryConfig{
+			Name:          "Last logged film",
+			ValueTemplate: "{{ value_json.film }}",
+			Icon:          "mdi:filmstrip",
+		},
+	},
+	{
+		component: "binary_sensor",
+		objectId:  "letterboxd",
+		topic:     "letterboxd",
+		config: discoveryConfig{
+			Name:          "Letterboxd login",
+			ValueTemplate: "{{ 'ON' if value_json.is_connected else 'OFF' }}",
+			DeviceClass:   "connectivity",
+		},
+	},
+}
+
+// discoveryId returns a Home Assistant object ID for the username
+func discoveryId(username string) string {
+	return "emboxd_" + nonIdCharacters.ReplaceAllString(strings.ToLower(username), "_")
+}
+
+// publishDiscovery registers every user's entities with Home Assistant
+func (p *Publisher) publishDiscovery() {
+	p.lock.Lock()
+	var usernames = make([]string, 0, len(p.users))
+	for _, user := range p.users {
+		usernames = append(usernames, user.username)
+	}
+	p.lock.Unlock()
+
+	for _, username := range usernames {
+		var nodeId = discoveryId(username)
+		for _, entity := range discoveryEntities {
+			var config = entity.config
+			config.UniqueId = nodeId + "_" + entity.objectId
+			config.StateTopic = p.userTopic(username, entity.topic)
+			config.JsonAttributesTopic = config.StateTopic
+			config.AvailabilityTopic = p.availabilityTopic()
+			config.Device = discoveryDevice{
+				Identifiers:  []string{nodeId},
+				Name:         "EmBoxd " + username,
+				Manufacturer: "EmBoxd",
+			}
+
+			var payload, err = json.Marshal(config)
+			if err != nil {
+				slog.Error("Failed to encode Home Assistant discovery config", slog.String("error", err.Error()))
+				continue
+			}
+			p.publish(p.discoveryTopic(entity.component, nodeId, entity.objectId), true, payload)
+		}
+	}
+}
+
+func (p *Publisher) discoveryTopic(component string, nodeId string, objectId string) string {
+	return strings.Join([]string{p.options.DiscoveryPrefix, component, nodeId, objectId, "config"}, "/")
+}
@@ -0,0 +1,386 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Default prefix of every topic published
+const _DEFAULT_TOPIC_PREFIX string = "emboxd"
+
+// Default prefix Home Assistant looks for discovery configs under
+const _DEFAULT_DISCOVERY_PREFIX string = "homeassistant"
+
+const _DEFAULT_CLIENT_ID string = "emboxd"
+
+// Time to wait for the first connection before retrying in the background
+const _CONNECT_TIMEOUT time.Duration = 10 * time.Second
+
+// Time to wait for a publish to be acknowledged before logging it as failed
+const _PUBLISH_TIMEOUT time.Duration = 10 * time.Second
+
+// A stopped film still counts as being watched for this long, e.g. while the
+// kettle boils
+const _PAUSED_TIMEOUT time.Duration = 30 * time.Minute
+
+const (
+	_STATE_PLAYING string = "playing"
+	_STATE_PAUSED  string = "paused"
+	_STATE_IDLE    string = "idle"
+)
+
+const (
+	_AVAILABILITY_ONLINE  string = "online"
+	_AVAILABILITY_OFFLINE string = "offline"
+)
+
+// Options configures a Publisher
+type Options struct {
+	Broker   string // e.g. "tcp://mosquitto:1883"
+	Username string
+	Password string
+	ClientID string
+	// Prefix of every topic published, "emboxd" if empty
+	TopicPrefix string
+	// Register sensors with Home Assistant under DiscoveryPrefix,
+	// "homeassistant" if empty
+	Discovery       bool
+	DiscoveryPrefix string
+}
+
+// Watching is a user's current film, published to <prefix>/<user>/watching
+type Watching struct {
+	State             string `json:"state"` // "playing", "paused" or "idle"
+	Film              string `json:"film"`  // Title and year, empty when idle
+	Title             string `json:"title,omitempty"`
+	Year              int    `json:"year,omitempty"`
+	ImdbId            string `json:"imdb_id,omitempty"`
+	Server            string `json:"server,omitempty"`
+	Player            string `json:"player,omitempty"`
+	Device            string `json:"device,omitempty"`
+	PositionSeconds   int    `json:"position_seconds"`
+	RuntimeSeconds    int    `json:"runtime_seconds"`
+	Progress          uint   `json:"progress"` // Position percentage
+	WatchedPercentage uint   `json:"watched_percentage"`
+}
+
+// LastLogged is the film a user last marked as watched or logged on
+// Letterboxd, published to <prefix>/<user>/last_logged
+type LastLogged struct {
+	Film    string    `json:"film"`
+	Title   string    `json:"title"`
+	Year    int       `json:"year,omitempty"`
+	ImdbId  string    `json:"imdb_id"`
+	Action  string    `json:"action"` // "watched" or "logged"
+	Watched time.Time `json:"watched"`
+}
+
+// publisherUser is a Letterboxd account, with the processors of every media
+// server user that syncs to it
+type publisherUser struct {
+	username   string
+	status     func() letterboxd.Status // The worker's connection state
+	processors []*notification.Processor
+}
+
+// Publisher posts events, worker connection states and what users are
+// watching to an MQTT broker. States are retained, and only published when
+// they change.
+type Publisher struct {
+	client  paho.Client
+	options Options
+
+	lock     sync.Mutex
+	users    []*publisherUser
+	retained map[string][]byte // Last payload of each state topic, republished on reconnect
+	now      func() time.Time
+}
+
+func New(options Options) (*Publisher, error) {
+	if options.Broker == "" {
+		return nil, fmt.Errorf("mqtt needs a broker")
+	}
+	if options.ClientID == "" {
+		options.ClientID = _DEFAULT_CLIENT_ID
+	}
+	if options.TopicPrefix == "" {
+		options.TopicPrefix = _DEFAULT_TOPIC_PREFIX
+	}
+	if options.DiscoveryPrefix == "" {
+		options.DiscoveryPrefix = _DEFAULT_DISCOVERY_PREFIX
+	}
+
+	var publisher = &Publisher{
+		options:  options,
+		retained: make(map[string][]byte),
+		now:      time.Now,
+	}
+
+	var clientOptions = paho.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetWill(publisher.availabilityTopic(), _AVAILABILITY_OFFLINE, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(publisher.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("Lost connection to MQTT broker", slog.String("error", err.Error()))
+		})
+	publisher.client = paho.NewClient(clientOptions)
+	return publisher, nil
+}
+
+// AddUser publishes the state of a Letterboxd account, e.g. worker.Status,
+// and what its media server users are watching
+func (p *Publisher) AddUser(username string, status func() letterboxd.Status, processor *notification.Processor) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, user := range p.users {
+		if user.username == username {
+			if !slices.Contains(user.processors, processor) {
+				user.processors = append(user.processors, processor)
+			}
+			return
+		}
+	}
+	p.users = append(p.users, &publisherUser{
+		username:   username,
+		status:     status,
+		processors: []*notification.Processor{processor},
+	})
+}
+
+// Connect connects to the broker, and keeps retrying in the background if
+// it can't
+func (p *Publisher) Connect() error {
+	var token = p.client.Connect()
+	if !token.WaitTimeout(_CONNECT_TIMEOUT) {
+		return fmt.Errorf("timed out connecting to %s, retrying in the background", p.options.Broker)
+	}
+	return token.Error()
+}
+
+// Close marks EmBoxd as offline and disconnects from the broker
+func (p *Publisher) Close() {
+	p.client.Publish(p.availabilityTopic(), 1, true, _AVAILABILITY_OFFLINE).WaitTimeout(_PUBLISH_TIMEOUT)
+	p.client.Disconnect(250)
+}
+
+// Start publishes new events from the history, and checks states at the
+// given interval, in the background
+func (p *Publisher) Start(eventHistory *history.Store, interval time.Duration) {
+	go p.publishEvents(eventHistory)
+	go func() {
+		for {
+			p.Poll()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// publishEvents publishes events as they are added to the history, catching
+// up on any missed if the subscription falls behind
+func (p *Publisher) publishEvents(eventHistory *history.Store) {
+	var lastSequence = eventHistory.LastSequence()
+	for {
+		var subscription = eventHistory.Subscribe(0)
+		for _, event := range eventHistory.Since(lastSequence) {
+			p.PublishEvent(event)
+			lastSequence = event.Sequence
+		}
+		for event := range subscription.Events() {
+			if event.Sequence > lastSequence {
+				p.PublishEvent(event)
+				lastSequence = event.Sequence
+			}
+		}
+		slog.Warn("MQTT publisher fell behind the event history, catching up")
+	}
+}
+
+// PublishEvent publishes an event to <prefix>/events
+func (p *Publisher) PublishEvent(event *history.Event) {
+	var payload, err = json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode event for MQTT", slog.String("error", err.Error()))
+		return
+	}
+	p.publish(p.topic("events"), false, payload)
+}
+
+// PublishOutcome updates the last logged film of the outcome's account, if
+// it marked a film as watched on Letterboxd
+func (p *Publisher) PublishOutcome(outcome letterboxd.Outcome) {
+	if outcome.Err != nil || outcome.Skipped != "" || outcome.DryRun {
+		return
+	}
+	if outcome.Event.Action != letterboxd.FilmWatched && outcome.Event.Action != letterboxd.FilmLogged {
+		return
+	}
+	p.publishState(p.userTopic(outcome.Username, "last_logged"), LastLogged{
+		Film:    filmName(outcome.Event.Title, outcome.Event.Year, outcome.Event.ImdbId),
+		Title:   outcome.Event.Title,
+		Year:    outcome.Event.Year,
+		ImdbId:  outcome.Event.ImdbId,
+		Action:  outcome.Event.Action.String(),
+		Watched: outcome.Event.Time,
+	})
+}
+
+// Poll publishes each account's connection state and what its users are
+// watching, where they have changed
+func (p *Publisher) Poll() {
+	p.lock.Lock()
+	var users = slices.Clone(p.users)
+	p.lock.Unlock()
+
+	for _, user := range users {
+		p.publishState(p.userTopic(user.username, "letterboxd"), user.status())
+		p.publishState(p.userTopic(user.username, "watching"), p.watching(user))
+	}
+}
+
+// watching returns the film a user is playing, or has stopped recently
+func (p *Publisher) watching(user *publisherUser) Watching {
+	var sessions []notification.Session
+	for _, processor := range user.processors {
+		sessions = append(sessions, processor.Sessions()...)
+	}
+	slices.SortFunc(sessions, func(x, y notification.Session) int {
+		if x.Playing != y.Playing {
+			if x.Playing {
+				return -1
+			}
+			return 1
+		}
+		return y.Time.Compare(x.Time)
+	})
+
+	var now = p.now()
+	if len(sessions) == 0 || (!sessions[0].Playing && now.Sub(sessions[0].Time) > _PAUSED_TIMEOUT) {
+		return Watching{State: _STATE_IDLE}
+	}
+
+	var session = sessions[0]
+	var watching = Watching{
+		State:             _STATE_PAUSED,
+		Film:              filmName(session.Title, session.Year, session.ImdbId),
+		Title:             session.Title,
+		Year:              session.Year,
+		ImdbId:            session.ImdbId,
+		Server:            session.Server.String(),
+		Player:            session.Player,
+		Device:            session.Device,
+		RuntimeSeconds:    int(session.Runtime.Seconds()),
+		WatchedPercentage: session.WatchedPercentage(),
+	}
+	var position = session.Position
+	if session.Playing {
+		// Notifications only arrive now and then, so carry on from the last one
+		watching.State = _STATE_PLAYING
+		position += max(now.Sub(session.Time), 0)
+		if session.Runtime > 0 {
+			position = min(position, session.Runtime)
+		}
+	}
+	watching.PositionSeconds = int(position.Seconds())
+	if session.Runtime > 0 {
+		watching.Progress = uint(position * 100 / session.Runtime)
+	}
+	return watching
+}
+
+// publishState publishes a retained state, if it differs from the last one
+func (p *Publisher) publishState(topic string, state any) {
+	var payload, err = json.Marshal(state)
+	if err != nil {
+		slog.Error("Failed to encode state for MQTT", slog.String("topic", topic), slog.String("error", err.Error()))
+		return
+	}
+
+	p.lock.Lock()
+	var unchanged = string(p.retained[topic]) == string(payload)
+	p.retained[topic] = payload
+	p.lock.Unlock()
+
+	if !unchanged {
+		p.publish(topic, true, payload)
+	}
+}
+
+// publish sends a message without waiting for it, failures are only logged
+func (p *Publisher) publish(topic string, retained bool, payload []byte) {
+	var token = p.client.Publish(topic, 1, retained, payload)
+	go func() {
+		if !token.WaitTimeout(_PUBLISH_TIMEOUT) {
+			slog.Warn("Timed out publishing to MQTT", slog.String("topic", topic))
+		} else if err := token.Error(); err != nil {
+			slog.Warn("Failed to publish to MQTT", slog.String("topic", topic), slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// onConnect marks EmBoxd as online and republishes the retained states, in
+// case the broker lost them
+func (p *Publisher) onConnect(client paho.Client) {
+	slog.Info("Connected to MQTT broker", slog.String("broker", p.options.Broker))
+	p.publish(p.availabilityTopic(), true, []byte(_AVAILABILITY_ONLINE))
+
+	p.lock.Lock()
+	var retained = make(map[string][]byte, len(p.retained))
+	for topic, payload := range p.retained {
+		retained[topic] = payload
+	}
+	p.lock.Unlock()
+	for topic, payload := range retained {
+		p.publish(topic, true, payload)
+	}
+
+	if p.options.Discovery {
+		p.publishDiscovery()
+		// Home Assistant announces itself when it starts, and needs the
+		// configs again if it lost them
+		client.Subscribe(p.options.DiscoveryPrefix+"/status", 1, func(_ paho.Client, message paho.Message) {
+			if string(message.Payload()) == _AVAILABILITY_ONLINE {
+				p.publishDiscovery()
+			}
+		})
+	}
+}
+
+func (p *Publisher) topic(name string) string {
+	return p.options.TopicPrefix + "/" + name
+}
+
+func (p *Publisher) userTopic(username string, name string) string {
+	return p.topic(username + "/" + name)
+}
+
+func (p *Publisher) availabilityTopic() string {
+	return p.topic("status")
+}
+
+func filmName(title string, year int, imdbId string) string {
+	var name = title
+	if name == "" {
+		name = imdbId
+	}
+	if year != 0 {
+		name += fmt.Sprintf(" (%d)", year)
+	}
+	return name
+}
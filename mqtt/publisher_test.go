@@ -0,0 +1,187 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/notification"
+
+	broker "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBroker is an in-process broker that remembers the last message on
+// each topic
+type testBroker struct {
+	server  *broker.Server
+	address string
+
+	lock     sync.Mutex
+	messages map[string]packets.Packet
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	var server = broker.New(&broker.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	assert.NoError(t, server.AddHook(new(auth.AllowHook), nil))
+
+	var listener = listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	assert.NoError(t, server.AddListener(listener))
+	assert.NoError(t, server.Serve())
+	t.Cleanup(func() { server.Close() })
+
+	var test = &testBroker{
+		server:   server,
+		address:  "tcp://" + listener.Address(),
+		messages: make(map[string]packets.Packet),
+	}
+	assert.NoError(t, server.Subscribe("#", 1, func(_ *broker.Client, _ packets.Subscription, packet packets.Packet) {
+		test.lock.Lock()
+		defer test.lock.Unlock()
+		test.messages[packet.TopicName] = packet
+	}))
+	return test
+}
+
+// waitFor waits for a message on the topic that decodes into value and
+// satisfies the condition
+func waitFor[T any](t *testing.T, test *testBroker, topic string, condition func(T) bool) (T, packets.Packet) {
+	var value T
+	var packet packets.Packet
+	assert.Eventually(t, func() bool {
+		test.lock.Lock()
+		defer test.lock.Unlock()
+
+		var ok bool
+		if packet, ok = test.messages[topic]; !ok {
+			return false
+		}
+		var decoded T
+		if json.Unmarshal(packet.Payload, &decoded) != nil || !condition(decoded) {
+			return false
+		}
+		value = decoded
+		return true
+	}, 5*time.Second, 10*time.Millisecond, "no matching message on %s", topic)
+	return value, packet
+}
+
+func TestPublisher(t *testing.T) {
+	var test = newTestBroker(t)
+	var now = time.Date(2026, 10, 1, 20, 0, 0, 0, time.UTC)
+
+	var status = func() letterboxd.Status { return letterboxd.Status{Username: "john_doe"} }
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var eventHistory = history.NewStore(10)
+
+	var publisher, err = New(Options{Broker: test.address, Discovery: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	publisher.now = func() time.Time { return now }
+	publisher.AddUser("john_doe", status, &processor)
+	if !assert.NoError(t, publisher.Connect()) {
+		return
+	}
+	defer publisher.Close()
+	publisher.Start(eventHistory, time.Hour)
+
+	// Discovery configs and availability
+	var config, packet = waitFor(t, test, "homeassistant/sensor/emboxd_john_doe/now_watching/config", func(discoveryConfig) bool { return true })
+	assert.True(t, packet.FixedHeader.Retain)
+	assert.Equal(t, "emboxd/john_doe/watching", config.StateTopic)
+	assert.Equal(t, "emboxd/status", config.AvailabilityTopic)
+	assert.Equal(t, "emboxd_john_doe_now_watching", config.UniqueId)
+	waitFor(t, test, "homeassistant/sensor/emboxd_john_doe/last_logged/config", func(discoveryConfig) bool { return true })
+	waitFor(t, test, "homeassistant/binary_sensor/emboxd_john_doe/letterboxd/config", func(discoveryConfig) bool { return true })
+	assert.Eventually(t, func() bool {
+		test.lock.Lock()
+		defer test.lock.Unlock()
+		return string(test.messages["emboxd/status"].Payload) == "online"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Nothing playing, and the worker hasn't been probed yet
+	waitFor(t, test, "emboxd/john_doe/watching", func(watching Watching) bool { return watching.State == "idle" })
+	waitFor(t, test, "emboxd/john_doe/letterboxd", func(status letterboxd.Status) bool { return !status.IsConnected })
+
+	// Playing, 20 minutes after starting at 10 minutes in
+	processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		Metadata: notification.Metadata{
+			Server:   notification.Emby,
+			Username: "john",
+			ImdbId:   "tt0133093",
+			Title:    "The Matrix",
+			Year:     1999,
+			Time:     now.Add(-20 * time.Minute),
+		},
+		Playing:  true,
+		Position: 10 * time.Minute,
+		Runtime:  120 * time.Minute,
+	})
+	publisher.Poll()
+	var watching, _ = waitFor(t, test, "emboxd/john_doe/watching", func(watching Watching) bool { return watching.State == "playing" })
+	assert.Equal(t, "The Matrix (1999)", watching.Film)
+	assert.Equal(t, "emby", watching.Server)
+	assert.Equal(t, 30*60, watching.PositionSeconds)
+	assert.Equal(t, uint(25), watching.Progress)
+
+	// Events from the history
+	eventHistory.Add(&history.Event{Type: history.EventTypeWatched, Source: history.SourceEmby, Username: "john", MediaID: "tt0133093"})
+	var event, eventPacket = waitFor(t, test, "emboxd/events", func(event history.Event) bool { return event.MediaID == "tt0133093" })
+	assert.False(t, eventPacket.FixedHeader.Retain)
+	assert.Equal(t, history.EventTypeWatched, event.Type)
+
+	// Only films marked as watched for real are logged
+	var film = letterboxd.Film{ImdbId: "tt0133093", Title: "The Matrix", Year: 1999}
+	publisher.PublishOutcome(letterboxd.Outcome{
+		Username: "john_doe",
+		Event:    letterboxd.Event{Film: letterboxd.Film{ImdbId: "tt0000001", Title: "Dry Run"}, Action: letterboxd.FilmLogged},
+		DryRun:   true,
+	})
+	publisher.PublishOutcome(letterboxd.Outcome{
+		Username: "john_doe",
+		Event:    letterboxd.Event{Film: film, Action: letterboxd.FilmLogged, Time: now},
+	})
+	var lastLogged, _ = waitFor(t, test, "emboxd/john_doe/last_logged", func(LastLogged) bool { return true })
+	assert.Equal(t, "The Matrix (1999)", lastLogged.Film)
+	assert.Equal(t, "logged", lastLogged.Action)
+	assert.True(t, now.Equal(lastLogged.Watched))
+}
+
+func TestWatchingPausedTimeout(t *testing.T) {
+	var now = time.Date(2026, 10, 1, 20, 0, 0, 0, time.UTC)
+	var processor = notification.NewProcessor(func(letterboxd.Event) {})
+	var publisher = &Publisher{now: func() time.Time { return now }}
+	var user = &publisherUser{username: "john_doe", processors: []*notification.Processor{&processor}}
+
+	processor.ProcessPlaybackNotification(notification.PlaybackNotification{
+		Metadata: notification.Metadata{
+			Server:   notification.Plex,
+			Username: "john",
+			ImdbId:   "tt0133093",
+			Title:    "The Matrix",
+			Time:     now.Add(-10 * time.Minute),
+		},
+		Playing:  false,
+		Position: 60 * time.Minute,
+		Runtime:  120 * time.Minute,
+	})
+
+	var watching = publisher.watching(user)
+	assert.Equal(t, "paused", watching.State)
+	assert.Equal(t, uint(50), watching.Progress)
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, Watching{State: "idle"}, publisher.watching(user))
+}
@@ -1,9 +1,12 @@
 package notification
 
 import (
+	"strings"
 	"time"
 )
 
+import "emboxd/letterboxd"
+
 type MediaServer int
 
 const (
@@ -11,11 +14,32 @@ const (
 	Plex
 )
 
+func (s MediaServer) String() string {
+	switch s {
+	case Emby:
+		return "emby"
+	case Plex:
+		return "plex"
+	default:
+		return "unknown"
+	}
+}
+
 type Metadata struct {
 	Server   MediaServer
 	Username string
 	ImdbId   string
+	TmdbId   string
+	ItemId   string // Emby item ID or Plex rating key
+	Title    string
+	Year     int
 	Time     time.Time
+
+	// Where the film is being watched, for diary templates
+	Player     string
+	Device     string
+	Library    string
+	Resolution string
 }
 
 type WatchedNotification struct {
@@ -24,9 +48,82 @@ type WatchedNotification struct {
 	Runtime time.Duration
 }
 
+// FavoriteNotification reports a film being favourited or unfavourited
+type FavoriteNotification struct {
+	Metadata
+	Favorite bool
+}
+
 type PlaybackNotification struct {
 	Metadata
 	Playing  bool
 	Position time.Duration
 	Runtime  time.Duration
 }
+
+// film returns the identity of the notification's film for Letterboxd
+func (m Metadata) film() letterboxd.Film {
+	return letterboxd.Film{
+		ImdbId: m.ImdbId,
+		TmdbId: m.TmdbId,
+		Server: m.Server.String(),
+		ItemId: m.ItemId,
+		Title:  m.Title,
+		Year:   m.Year,
+	}
+}
+
+// playback returns where the notification's film is being watched
+func (m Metadata) playback() letterboxd.Playback {
+	return letterboxd.Playback{
+		Player:     m.Player,
+		Device:     m.Device,
+		Library:    m.Library,
+		Resolution: m.Resolution,
+	}
+}
+
+// Resolution returns a resolution label such as "1080p" for a video height
+func Resolution(height int) string {
+	switch {
+	case height <= 0:
+		return ""
+	case height >= 2000:
+		return "4k"
+	case height >= 1000:
+		return "1080p"
+	case height >= 700:
+		return "720p"
+	case height >= 480:
+		return "480p"
+	default:
+		return "sd"
+	}
+}
+
+// PlexResolution returns a resolution label for a Plex videoResolution such as
+// "1080" or "4k", falling back to the video height
+func PlexResolution(videoResolution string, height int) string {
+	switch resolution := strings.ToLower(videoResolution); resolution {
+	case "":
+		return Resolution(height)
+	case "4k", "sd":
+		return resolution
+	default:
+		// Plex reports heights such as "1080" and "720"
+		return strings.TrimSuffix(resolution, "p") + "p"
+	}
+}
+
+// key identifies the notification's film across notifications, falling back
+// to other identifiers for films only known through a manual mapping
+func (m Metadata) key() string {
+	switch {
+	case m.ImdbId != "":
+		return m.ImdbId
+	case m.TmdbId != "":
+		return "tmdb:" + m.TmdbId
+	default:
+		return m.Server.String() + ":" + m.ItemId
+	}
+}
@@ -0,0 +1,70 @@
+package notification
+
+import "time"
+
+// Max gap between watched intervals that is merged, absorbing the drift
+// between the wall clock and the playback position in progress reports
+const _MAX_COVERAGE_GAP time.Duration = 10 * time.Second
+
+// Interval is a part of a film's runtime, from Start to End
+type Interval struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Coverage is the parts of a film's runtime that were watched, as sorted
+// intervals that don't overlap, so parts watched twice count once and
+// skipped parts are left out
+type Coverage []Interval
+
+// add returns the coverage with the interval from start to end merged in
+func (c Coverage) add(start time.Duration, end time.Duration) Coverage {
+	if end <= start {
+		return c
+	}
+
+	var merged = make(Coverage, 0, len(c)+1)
+	var added = Interval{Start: start, End: end}
+	for _, interval := range c {
+		switch {
+		case interval.End+_MAX_COVERAGE_GAP < added.Start:
+			merged = append(merged, interval)
+		case added.End+_MAX_COVERAGE_GAP < interval.Start:
+			merged = append(merged, added)
+			added = interval
+		default:
+			added = Interval{Start: min(interval.Start, added.Start), End: max(interval.End, added.End)}
+		}
+	}
+	return append(merged, added)
+}
+
+// Duration returns how much of the runtime was watched
+func (c Coverage) Duration() time.Duration {
+	var duration time.Duration
+	for _, interval := range c {
+		duration += interval.End - interval.Start
+	}
+	return duration
+}
+
+// Gaps returns the parts of the runtime before position that weren't watched,
+// e.g. because they were skipped
+func (c Coverage) Gaps(position time.Duration) []Interval {
+	var gaps []Interval
+	var watched time.Duration
+	for _, interval := range c {
+		if interval.Start >= position {
+			break
+		}
+		if interval.Start > watched {
+			gaps = append(gaps, Interval{Start: watched, End: interval.Start})
+		}
+		watched = max(watched, interval.End)
+	}
+	if watched < position {
+		gaps = append(gaps, Interval{Start: watched, End: position})
+	}
+	return gaps
+}
+
@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverage(t *testing.T) {
+	var m = time.Minute
+	var tests = []struct {
+		name      string
+		intervals []Interval // Added in order
+		expected  Coverage
+		duration  time.Duration
+	}{
+		{"empty", nil, nil, 0},
+		{"single", []Interval{{0, 30 * m}}, Coverage{{0, 30 * m}}, 30 * m},
+		{"adjacent", []Interval{{0, 30 * m}, {30 * m, 60 * m}}, Coverage{{0, 60 * m}}, 60 * m},
+		{"small gap merged", []Interval{{0, 30 * m}, {30*m + 5*time.Second, 60 * m}}, Coverage{{0, 60 * m}}, 60 * m},
+		{"rewatched", []Interval{{0, 60 * m}, {30 * m, 90 * m}}, Coverage{{0, 90 * m}}, 90 * m},
+		{"rewatched inside", []Interval{{0, 60 * m}, {10 * m, 20 * m}}, Coverage{{0, 60 * m}}, 60 * m},
+		{"skipped", []Interval{{0, 10 * m}, {50 * m, 60 * m}}, Coverage{{0, 10 * m}, {50 * m, 60 * m}}, 20 * m},
+		{"watched out of order", []Interval{{50 * m, 60 * m}, {0, 10 * m}, {20 * m, 30 * m}}, Coverage{{0, 10 * m}, {20 * m, 30 * m}, {50 * m, 60 * m}}, 30 * m},
+		{"gap filled", []Interval{{0, 10 * m}, {50 * m, 60 * m}, {5 * m, 55 * m}}, Coverage{{0, 60 * m}}, 60 * m},
+		{"empty interval", []Interval{{30 * m, 30 * m}, {40 * m, 35 * m}}, nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var coverage Coverage
+			for _, interval := range test.intervals {
+				coverage = coverage.add(interval.Start, interval.End)
+			}
+			assert.Equal(t, test.expected, coverage)
+			assert.Equal(t, test.duration, coverage.Duration())
+		})
+	}
+}
+
+func TestCoverageGaps(t *testing.T) {
+	var m = time.Minute
+	var coverage = Coverage{{5 * m, 10 * m}, {50 * m, 60 * m}}
+	assert.Equal(t, []Interval{{0, 5 * m}, {10 * m, 50 * m}, {60 * m, 70 * m}}, coverage.Gaps(70*m))
+	assert.Equal(t, []Interval{{0, 5 * m}, {10 * m, 30 * m}}, coverage.Gaps(30*m))
+	assert.Empty(t, Coverage{{0, 60 * m}}.Gaps(60*m))
+}
@@ -0,0 +1,85 @@
+package notification
+
+import (
+	"log/slog"
+	"time"
+
+	"emboxd/letterboxd"
+)
+
+// Action of a decision that didn't send an event to Letterboxd
+const _ACTION_NONE string = "none"
+
+// Reason explains why a notification was or wasn't sent to Letterboxd
+type Reason string
+
+const (
+	// ReasonPlaying is playback that started or resumed, judged once it stops
+	ReasonPlaying Reason = "playing"
+	// ReasonDuplicateStop is a stop repeated shortly after the previous one
+	ReasonDuplicateStop Reason = "duplicate_stop"
+	// ReasonPositionBelowThreshold is playback stopped before the end, kept
+	// as a session until playback carries on
+	ReasonPositionBelowThreshold Reason = "position_below_threshold"
+	// ReasonWatchedBelowThreshold is playback stopped at the end, or a film
+	// marked played, without enough of it watched, e.g. after skipping ahead
+	ReasonWatchedBelowThreshold Reason = "watched_below_threshold"
+	// ReasonWatched is playback stopped at the end, or a film marked played,
+	// after enough of it was watched
+	ReasonWatched Reason = "watched"
+	// ReasonMarkedUnplayed is a film marked unplayed on the media server
+	ReasonMarkedUnplayed Reason = "marked_unplayed"
+	// ReasonFavorite is a film favourited or unfavourited on the media server
+	ReasonFavorite Reason = "favorite"
+	// ReasonFavoriteUnchanged is a rating or user data change that didn't
+	// favourite or unfavourite the film, e.g. a new rating
+	ReasonFavoriteUnchanged Reason = "favorite_unchanged"
+)
+
+// Decision is what the processor did with a notification, and why
+type Decision struct {
+	Action             string     `json:"action"` // Letterboxd action sent, or "none"
+	Reason             Reason     `json:"reason"`
+	WatchedPercentage  uint       `json:"watched_percentage"`
+	PositionPercentage uint       `json:"position_percentage"`
+	Thresholds         Thresholds `json:"thresholds"`
+}
+
+// Sent returns true if the notification was sent to Letterboxd
+func (d Decision) Sent() bool {
+	return d.Action != _ACTION_NONE
+}
+
+func newDecision(reason Reason, watched time.Duration, position time.Duration, runtime time.Duration) Decision {
+	return Decision{
+		Action:             _ACTION_NONE,
+		Reason:             reason,
+		WatchedPercentage:  percentage(watched, runtime),
+		PositionPercentage: percentage(position, runtime),
+		Thresholds:         LoggingThresholds(),
+	}
+}
+
+// send records the Letterboxd action of the decision
+func (d Decision) send(action letterboxd.Action) Decision {
+	d.Action = action.String()
+	return d
+}
+
+func (d Decision) log(metadata Metadata) {
+	slog.Info("Decided on notification",
+		slog.String("username", metadata.Username),
+		slog.String("id", metadata.key()),
+		slog.String("action", d.Action),
+		slog.String("reason", string(d.Reason)),
+		slog.Uint64("watchedPercentage", uint64(d.WatchedPercentage)),
+		slog.Uint64("positionPercentage", uint64(d.PositionPercentage)))
+}
+
+// percentage returns a duration as a percentage of the runtime
+func percentage(duration time.Duration, runtime time.Duration) uint {
+	if runtime <= 0 || duration <= 0 {
+		return 0
+	}
+	return uint(duration.Nanoseconds() * 100 / runtime.Nanoseconds())
+}
@@ -3,6 +3,7 @@ package notification
 import (
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 )
 
@@ -17,89 +18,313 @@ const _MIN_POSITION_PERCENTAGE uint = 90
 // Max elapsed time to ignore consecutive playback stop notifications
 const _MAX_DUPLICATE_STOP_PLAYBACK_ELAPSED_TIME time.Duration = 2 * time.Minute
 
+// Max interval between checks for expired sessions
+const _MAX_SESSION_EXPIRY_INTERVAL time.Duration = 10 * time.Minute
+
+// Age of the last playback notification after which a session is discarded
+const DefaultSessionMaxAge time.Duration = 7 * 24 * time.Hour
+
+// Thresholds are the percentages of the runtime playback is compared against
+type Thresholds struct {
+	Watched  uint `json:"watched_percentage"`  // Watched duration to log the film as actually watched
+	Position uint `json:"position_percentage"` // Stop position to evaluate if the film should be logged
+}
+
+// LoggingThresholds returns the percentages a film must reach to be logged
+func LoggingThresholds() Thresholds {
+	return Thresholds{Watched: _MIN_WATCHED_PERCENTAGE, Position: _MIN_POSITION_PERCENTAGE}
+}
+
 type Processor struct {
+	lock                              *sync.Mutex
 	callback                          func(letterboxd.Event)
-	watchedDurationByImdbId           map[string]time.Duration
-	playbackStartNotificationByImdbId map[string]PlaybackNotification
+	coverageByImdbId                  map[string]Coverage
+	playbackStartNotificationByImdbId map[string]PlaybackNotification // Start of the span being played
 	playbackStopTimeByImdbId          map[string]time.Time
+	lastPlaybackNotificationByImdbId  map[string]PlaybackNotification
+	// Films last seen favourited, only those so it doesn't grow with every
+	// rating or user data change
+	favoriteImdbIds map[string]struct{}
 }
 
 func NewProcessor(callback func(letterboxd.Event)) Processor {
 	return Processor{
+		lock:                              &sync.Mutex{},
 		callback:                          callback,
-		watchedDurationByImdbId:           make(map[string]time.Duration),
+		coverageByImdbId:                  make(map[string]Coverage),
 		playbackStartNotificationByImdbId: make(map[string]PlaybackNotification),
 		playbackStopTimeByImdbId:          make(map[string]time.Time),
+		lastPlaybackNotificationByImdbId:  make(map[string]PlaybackNotification),
+		favoriteImdbIds:                   make(map[string]struct{}),
+	}
+}
+
+// Session is a film being played, or stopped before it was logged
+type Session struct {
+	Metadata
+	ID       string // IMDb ID or "tmdb:<id>"
+	Playing  bool
+	Position time.Duration
+	Runtime  time.Duration
+	// Parts watched up to the last playback notification
+	Coverage Coverage
+	Watched  time.Duration
+}
+
+// WatchedPercentage returns the watched duration as a percentage of the runtime
+func (s Session) WatchedPercentage() uint {
+	return percentage(s.Watched, s.Runtime)
+}
+
+// PositionPercentage returns the last position as a percentage of the runtime
+func (s Session) PositionPercentage() uint {
+	return percentage(s.Position, s.Runtime)
+}
+
+// Sessions returns the films with playback that hasn't been logged yet
+func (p *Processor) Sessions() []Session {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var sessions = make([]Session, 0, len(p.lastPlaybackNotificationByImdbId))
+	for key, last := range p.lastPlaybackNotificationByImdbId {
+		var session = Session{
+			Metadata: last.Metadata,
+			ID:       key,
+			Playing:  last.Playing,
+			Position: last.Position,
+			Runtime:  last.Runtime,
+			Coverage: p.coverageByImdbId[key],
+			Watched:  p.coverageByImdbId[key].Duration(),
+		}
+		sessions = append(sessions, session)
 	}
+	return sessions
 }
 
-func (p *Processor) ProcessWatchedNotification(notification WatchedNotification) {
+// lastPlayback returns the last playback notification of a user's session for
+// a film, identified by IMDb ID or "tmdb:<id>"
+func (p *Processor) lastPlayback(username string, id string) (PlaybackNotification, bool) {
+	var last, ok = p.lastPlaybackNotificationByImdbId[id]
+	return last, ok && last.Username == username
+}
+
+// discard forgets a session's playback
+func (p *Processor) discard(key string) {
+	delete(p.coverageByImdbId, key)
+	delete(p.playbackStartNotificationByImdbId, key)
+	delete(p.playbackStopTimeByImdbId, key)
+	delete(p.lastPlaybackNotificationByImdbId, key)
+}
+
+// CommitSession logs a session's film regardless of how much was watched, and
+// returns false if the user has no session for it
+func (p *Processor) CommitSession(username string, id string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var last, ok = p.lastPlayback(username, id)
+	if !ok {
+		return false
+	}
+	slog.Info("Committing playback session", slog.String("username", username), slog.String("id", id))
+	p.discard(id)
+
+	p.callback(letterboxd.Event{
+		Film:     last.film(),
+		Playback: last.playback(),
+		Action:   letterboxd.FilmLogged,
+		Time:     last.Time,
+	})
+	return true
+}
+
+// DiscardSession forgets a session without logging its film, and returns false
+// if the user has no session for it
+func (p *Processor) DiscardSession(username string, id string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.lastPlayback(username, id); !ok {
+		return false
+	}
+	slog.Info("Discarding playback session", slog.String("username", username), slog.String("id", id))
+	p.discard(id)
+	return true
+}
+
+// ExpireSessions discards sessions without a playback notification since
+// maxAge ago, and returns how many were discarded
+func (p *Processor) ExpireSessions(maxAge time.Duration) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var expired int
+	for key, last := range p.lastPlaybackNotificationByImdbId {
+		if time.Since(last.Time) > maxAge {
+			slog.Info("Expiring stale playback session",
+				slog.String("username", last.Username),
+				slog.String("id", key),
+				slog.Time("lastPlayback", last.Time))
+			p.discard(key)
+			expired++
+		}
+	}
+	return expired
+}
+
+// StartExpiry periodically discards sessions older than maxAge in the
+// background
+func (p *Processor) StartExpiry(maxAge time.Duration) {
+	go func() {
+		var ticker = time.NewTicker(min(maxAge, _MAX_SESSION_EXPIRY_INTERVAL))
+		defer ticker.Stop()
+		for range ticker.C {
+			p.ExpireSessions(maxAge)
+		}
+	}()
+}
+
+// ProcessWatchedNotification logs a film marked played, or only marks it
+// watched if not enough of its playback was seen, and unwatches a film marked
+// unplayed
+func (p *Processor) ProcessWatchedNotification(notification WatchedNotification) Decision {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	slog.Info(fmt.Sprintf("Processing watched notification %+v", notification))
 
-	var action letterboxd.Action
+	var decision = newDecision(ReasonMarkedUnplayed, p.coverageByImdbId[notification.key()].Duration(), 0, notification.Runtime)
+	var action = letterboxd.FilmUnwatched
 	if notification.Watched {
-		var watchedPercentage = uint(p.watchedDurationByImdbId[notification.ImdbId].Nanoseconds() * 100 / notification.Runtime.Nanoseconds())
-		if watchedPercentage >= _MIN_WATCHED_PERCENTAGE {
+		if decision.WatchedPercentage >= _MIN_WATCHED_PERCENTAGE {
+			decision.Reason = ReasonWatched
 			action = letterboxd.FilmLogged
 		} else {
+			decision.Reason = ReasonWatchedBelowThreshold
 			action = letterboxd.FilmWatched
 		}
+	}
+	decision = decision.send(action)
+	decision.log(notification.Metadata)
+
+	p.discard(notification.key())
+
+	p.callback(letterboxd.Event{
+		Film:     notification.film(),
+		Playback: notification.playback(),
+		Action:   action,
+		Time:     notification.Time,
+	})
+	return decision
+}
+
+// ProcessFavoriteNotification likes a film when it is favourited, and unlikes
+// it only when it was seen favourited before. Media servers also report
+// ratings and other user data changes this way, which mustn't unlike a film
+// liked on Letterboxd.
+func (p *Processor) ProcessFavoriteNotification(notification FavoriteNotification) Decision {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	slog.Info(fmt.Sprintf("Processing favorite notification %+v", notification))
+
+	var _, wasFavorite = p.favoriteImdbIds[notification.key()]
+	if notification.Favorite {
+		p.favoriteImdbIds[notification.key()] = struct{}{}
 	} else {
-		action = letterboxd.FilmUnwatched
+		delete(p.favoriteImdbIds, notification.key())
+	}
+	if wasFavorite == notification.Favorite {
+		var decision = newDecision(ReasonFavoriteUnchanged, 0, 0, 0)
+		decision.log(notification.Metadata)
+		return decision
 	}
 
-	delete(p.watchedDurationByImdbId, notification.ImdbId)
-	delete(p.playbackStartNotificationByImdbId, notification.ImdbId)
-	delete(p.playbackStopTimeByImdbId, notification.ImdbId)
+	var action = letterboxd.FilmUnliked
+	if notification.Favorite {
+		action = letterboxd.FilmLiked
+	}
+	var decision = newDecision(ReasonFavorite, 0, 0, 0).send(action)
+	decision.log(notification.Metadata)
 
 	p.callback(letterboxd.Event{
-		ImdbId: notification.ImdbId,
-		Action: action,
-		Time:   notification.Time,
+		Film:     notification.film(),
+		Playback: notification.playback(),
+		Action:   action,
+		Time:     notification.Time,
 	})
+	return decision
 }
 
-func (p *Processor) ProcessPlaybackNotification(notification PlaybackNotification) {
+// ProcessPlaybackNotification adds the parts of a film that were watched to
+// its coverage, and logs it once playback stops near the end after enough was
+// watched. Progress reports are playing notifications for a span already
+// started, and close it so seeking is noticed between reports.
+func (p *Processor) ProcessPlaybackNotification(notification PlaybackNotification) Decision {
+	p.lock.Lock()
+	defer p.lock.Unlock()
 	slog.Info(fmt.Sprintf("Processing playback notification %+v", notification))
 
+	var decision = p.processPlayback(notification)
+	decision.log(notification.Metadata)
+	return decision
+}
+
+// watch adds the span played since a start notification to a film's coverage
+func (p *Processor) watch(start PlaybackNotification, notification PlaybackNotification) {
+	var watchedDuration = min(
+		// Ensure movie was actually watched, a span that moved further than
+		// the time it took was skipped ahead in somewhere
+		notification.Time.Sub(start.Time),
+		// Ensure rewinding is not included
+		notification.Position-start.Position,
+	)
+	p.coverageByImdbId[notification.key()] = p.coverageByImdbId[notification.key()].add(start.Position, start.Position+watchedDuration)
+}
+
+func (p *Processor) processPlayback(notification PlaybackNotification) Decision {
+	p.lastPlaybackNotificationByImdbId[notification.key()] = notification
+
 	// TODO: setup DB for permanent storage of partially watched films
-	var startNotification, hasStart = p.playbackStartNotificationByImdbId[notification.ImdbId]
+	var startNotification, hasStart = p.playbackStartNotificationByImdbId[notification.key()]
 	if notification.Playing {
-		if !hasStart {
-			// Keep earliest playback notification for current session
-			p.playbackStartNotificationByImdbId[notification.ImdbId] = notification
-		}
-		delete(p.playbackStopTimeByImdbId, notification.ImdbId)
-	} else {
 		if hasStart {
-			var watchedDuration = min(
-				// Ensure movie was actually watched
-				notification.Time.Sub(startNotification.Time),
-				// Ensure rewinding/replaying is not included in watched duration
-				max(notification.Position - startNotification.Position, 0),
-			)
-			p.watchedDurationByImdbId[notification.ImdbId] += watchedDuration
-			delete(p.playbackStartNotificationByImdbId, notification.ImdbId)
-		} else if notification.Time.Sub(p.playbackStopTimeByImdbId[notification.ImdbId]) <= _MAX_DUPLICATE_STOP_PLAYBACK_ELAPSED_TIME {
-			slog.Info("Ignoring duplicate playback stop notification")
-			return
-		} else {
-			slog.Warn("Missing playback start time, set total watched duration to current playback position")
-			p.watchedDurationByImdbId[notification.ImdbId] = notification.Position
-		}
-		p.playbackStopTimeByImdbId[notification.ImdbId] = notification.Time
-
-		var positionPercentage = uint(notification.Position.Nanoseconds() * 100 / notification.Runtime.Nanoseconds())
-		if positionPercentage >= _MIN_POSITION_PERCENTAGE {
-			var watchedPercentage = uint(p.watchedDurationByImdbId[notification.ImdbId].Nanoseconds() * 100 / notification.Runtime.Nanoseconds())
-			if watchedPercentage >= _MIN_WATCHED_PERCENTAGE {
-				p.callback(letterboxd.Event{
-					ImdbId: notification.ImdbId,
-					Action: letterboxd.FilmLogged,
-					Time:   notification.Time,
-				})
-			}
-			delete(p.watchedDurationByImdbId, notification.ImdbId)
+			p.watch(startNotification, notification)
 		}
+		p.playbackStartNotificationByImdbId[notification.key()] = notification
+		delete(p.playbackStopTimeByImdbId, notification.key())
+		return newDecision(ReasonPlaying, p.coverageByImdbId[notification.key()].Duration(), notification.Position, notification.Runtime)
 	}
+
+	if hasStart {
+		p.watch(startNotification, notification)
+		delete(p.playbackStartNotificationByImdbId, notification.key())
+	} else if notification.Time.Sub(p.playbackStopTimeByImdbId[notification.key()]) <= _MAX_DUPLICATE_STOP_PLAYBACK_ELAPSED_TIME {
+		slog.Info("Ignoring duplicate playback stop notification")
+		return newDecision(ReasonDuplicateStop, p.coverageByImdbId[notification.key()].Duration(), notification.Position, notification.Runtime)
+	} else {
+		slog.Warn("Missing playback start time, assume film was watched up to current playback position")
+		p.coverageByImdbId[notification.key()] = p.coverageByImdbId[notification.key()].add(0, notification.Position)
+	}
+	p.playbackStopTimeByImdbId[notification.key()] = notification.Time
+
+	var decision = newDecision(ReasonPositionBelowThreshold, p.coverageByImdbId[notification.key()].Duration(), notification.Position, notification.Runtime)
+	if decision.PositionPercentage < _MIN_POSITION_PERCENTAGE {
+		return decision
+	}
+
+	delete(p.coverageByImdbId, notification.key())
+	delete(p.lastPlaybackNotificationByImdbId, notification.key())
+	if decision.WatchedPercentage < _MIN_WATCHED_PERCENTAGE {
+		decision.Reason = ReasonWatchedBelowThreshold
+		return decision
+	}
+
+	decision.Reason = ReasonWatched
+	p.callback(letterboxd.Event{
+		Film:     notification.film(),
+		Playback: notification.playback(),
+		Action:   letterboxd.FilmLogged,
+		Time:     notification.Time,
+	})
+	return decision.send(letterboxd.FilmLogged)
 }
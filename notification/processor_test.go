@@ -0,0 +1,175 @@
+package notification
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"emboxd/letterboxd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpireSessions(t *testing.T) {
+	var processor = NewProcessor(func(letterboxd.Event) {})
+	for id, age := range map[string]time.Duration{
+		"tt0133093": 8 * 24 * time.Hour,
+		"tt0234215": time.Hour,
+	} {
+		processor.ProcessPlaybackNotification(PlaybackNotification{
+			Metadata: Metadata{Server: Emby, Username: "john", ImdbId: id, Time: time.Now().Add(-age)},
+			Playing:  true,
+			Runtime:  136 * time.Minute,
+		})
+	}
+
+	assert.Equal(t, 1, processor.ExpireSessions(DefaultSessionMaxAge))
+	var sessions = processor.Sessions()
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, "tt0234215", sessions[0].ID)
+	}
+	assert.Equal(t, 0, processor.ExpireSessions(DefaultSessionMaxAge))
+}
+
+func TestPlaybackDecisions(t *testing.T) {
+	var start = time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)
+	var runtime = 100 * time.Minute
+	type playback struct {
+		after    time.Duration // Since start
+		playing  bool
+		position time.Duration
+	}
+	var tests = []struct {
+		name      string
+		playbacks []playback
+		expected  Decision // Of the last playback
+	}{
+		{
+			"playing",
+			[]playback{{0, true, 0}},
+			Decision{Action: "none", Reason: ReasonPlaying},
+		},
+		{
+			"stopped halfway",
+			[]playback{{0, true, 0}, {50 * time.Minute, false, 50 * time.Minute}},
+			Decision{Action: "none", Reason: ReasonPositionBelowThreshold, WatchedPercentage: 50, PositionPercentage: 50},
+		},
+		{
+			"watched to the end",
+			[]playback{{0, true, 0}, {95 * time.Minute, false, 95 * time.Minute}},
+			Decision{Action: "logged", Reason: ReasonWatched, WatchedPercentage: 95, PositionPercentage: 95},
+		},
+		{
+			"skipped to the end",
+			[]playback{{0, true, 0}, {10 * time.Minute, false, 10 * time.Minute}, {11 * time.Minute, true, 85 * time.Minute}, {21 * time.Minute, false, 95 * time.Minute}},
+			Decision{Action: "none", Reason: ReasonWatchedBelowThreshold, WatchedPercentage: 20, PositionPercentage: 95},
+		},
+		{
+			"rewatched the start and skipped to the end",
+			[]playback{{0, true, 0}, {40 * time.Minute, false, 40 * time.Minute}, {41 * time.Minute, true, 0}, {81 * time.Minute, false, 40 * time.Minute}, {82 * time.Minute, true, 90 * time.Minute}, {87 * time.Minute, false, 95 * time.Minute}},
+			Decision{Action: "none", Reason: ReasonWatchedBelowThreshold, WatchedPercentage: 45, PositionPercentage: 95},
+		},
+		{
+			"skipped ahead between progress reports",
+			[]playback{{0, true, 0}, {30 * time.Minute, true, 30 * time.Minute}, {31 * time.Minute, true, 70 * time.Minute}, {56 * time.Minute, false, 95 * time.Minute}},
+			Decision{Action: "none", Reason: ReasonWatchedBelowThreshold, WatchedPercentage: 56, PositionPercentage: 95},
+		},
+		{
+			"duplicate stop",
+			[]playback{{0, true, 0}, {50 * time.Minute, false, 50 * time.Minute}, {51 * time.Minute, false, 50 * time.Minute}},
+			Decision{Action: "none", Reason: ReasonDuplicateStop, WatchedPercentage: 50, PositionPercentage: 50},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events []letterboxd.Event
+			var processor = NewProcessor(func(event letterboxd.Event) {
+				events = append(events, event)
+			})
+			var decision Decision
+			for _, playback := range test.playbacks {
+				decision = processor.ProcessPlaybackNotification(PlaybackNotification{
+					Metadata: Metadata{Server: Emby, Username: "john", ImdbId: "tt0133093", Time: start.Add(playback.after)},
+					Playing:  playback.playing,
+					Position: playback.position,
+					Runtime:  runtime,
+				})
+			}
+
+			test.expected.Thresholds = LoggingThresholds()
+			assert.Equal(t, test.expected, decision)
+			assert.Equal(t, decision.Sent(), len(events) == 1)
+		})
+	}
+}
+
+func TestWatchedDecisions(t *testing.T) {
+	var tests = []struct {
+		name     string
+		watched  bool
+		played   time.Duration // Watched before the notification
+		expected Decision
+	}{
+		{"marked played after watching", true, 80 * time.Minute, Decision{Action: "logged", Reason: ReasonWatched, WatchedPercentage: 80}},
+		{"marked played without watching", true, 0, Decision{Action: "watched", Reason: ReasonWatchedBelowThreshold}},
+		{"marked unplayed", false, 0, Decision{Action: "unwatched", Reason: ReasonMarkedUnplayed}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var processor = NewProcessor(func(letterboxd.Event) {})
+			var metadata = Metadata{Server: Emby, Username: "john", ImdbId: "tt0133093", Time: time.Now().Add(-test.played)}
+			if test.played > 0 {
+				processor.ProcessPlaybackNotification(PlaybackNotification{Metadata: metadata, Playing: true, Runtime: 100 * time.Minute})
+				metadata.Time = time.Now()
+				processor.ProcessPlaybackNotification(PlaybackNotification{Metadata: metadata, Playing: false, Position: test.played, Runtime: 100 * time.Minute})
+			}
+
+			var decision = processor.ProcessWatchedNotification(WatchedNotification{Metadata: metadata, Watched: test.watched, Runtime: 100 * time.Minute})
+			test.expected.Thresholds = LoggingThresholds()
+			assert.Equal(t, test.expected, decision)
+		})
+	}
+}
+
+func TestFavoriteDecisions(t *testing.T) {
+	var tests = []struct {
+		name      string
+		favorites []bool
+		expected  []string // Action of each notification
+	}{
+		{"favourited", []bool{true}, []string{"liked"}},
+		{"unfavourited", []bool{true, false}, []string{"liked", "unliked"}},
+		{"updated without being favourited", []bool{false}, []string{"none"}},
+		{"updated while favourited", []bool{true, true}, []string{"liked", "none"}},
+		{"updated after being unfavourited", []bool{true, false, false}, []string{"liked", "unliked", "none"}},
+		{"favourited again", []bool{true, false, true}, []string{"liked", "unliked", "liked"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events []letterboxd.Event
+			var processor = NewProcessor(func(event letterboxd.Event) {
+				events = append(events, event)
+			})
+			var sent []string
+			for _, favorite := range test.favorites {
+				var decision = processor.ProcessFavoriteNotification(FavoriteNotification{
+					Metadata: Metadata{Server: Emby, Username: "john", ImdbId: "tt0133093", Time: time.Now()},
+					Favorite: favorite,
+				})
+				sent = append(sent, decision.Action)
+			}
+
+			assert.Equal(t, test.expected, sent)
+			// Only films still favourited are remembered
+			assert.Equal(t, test.favorites[len(test.favorites)-1], len(processor.favoriteImdbIds) == 1)
+			var actions = []string{}
+			for _, event := range events {
+				actions = append(actions, event.Action.String())
+			}
+			assert.Equal(t, slices.DeleteFunc(slices.Clone(test.expected), func(action string) bool { return action == "none" }), actions)
+		})
+	}
+}
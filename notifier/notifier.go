@@ -0,0 +1,260 @@
+package notifier
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"emboxd/letterboxd"
+)
+
+// Timeout for delivering a message to a target
+const _REQUEST_TIMEOUT time.Duration = 10 * time.Second
+
+// Messages waiting for delivery before new ones are dropped
+const _QUEUE_SIZE int = 100
+
+// Default title template for targets that show one
+const _DEFAULT_TITLE string = "EmBoxd"
+
+// Default message template for every target
+const _DEFAULT_TEMPLATE string = "{{summary}}"
+
+// Kind is what a message is about, which targets filter on
+type Kind string
+
+const (
+	KindSuccess  Kind = "success"   // A Letterboxd action succeeded
+	KindFailure  Kind = "failure"   // A Letterboxd action failed
+	KindAuthLost Kind = "auth_lost" // An account is no longer logged in to Letterboxd
+)
+
+// ParseKind accepts a kind as written in the configuration, e.g. "auth-lost"
+func ParseKind(kind string) (Kind, error) {
+	var parsed = Kind(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(kind)), "-", "_"))
+	switch parsed {
+	case KindSuccess, KindFailure, KindAuthLost:
+		return parsed, nil
+	}
+	return "", fmt.Errorf("unknown notification event %q", kind)
+}
+
+// Message is something to tell users about
+type Message struct {
+	Kind     Kind
+	Username string // Letterboxd username
+	Time     time.Time
+	// Values for templates, including "summary", a readable description
+	Vars map[string]string
+}
+
+// FromOutcome returns the message for a worker outcome; skipped events don't
+// need one
+func FromOutcome(outcome letterboxd.Outcome) (Message, bool) {
+	if outcome.Skipped != "" {
+		return Message{}, false
+	}
+
+	var vars = letterboxd.TemplateVars(outcome.Event)
+	vars["username"] = outcome.Username
+	vars["action"] = outcome.Action
+	vars["event"] = outcome.Event.Action.String()
+	vars["film"] = filmName(outcome.Event.Film)
+	vars["dry_run"] = strconv.FormatBool(outcome.DryRun)
+
+	var message = Message{
+		Kind:     KindSuccess,
+		Username: outcome.Username,
+		Time:     time.Now(),
+		Vars:     vars,
+	}
+	if outcome.Err != nil {
+		message.Kind = KindFailure
+		vars["error"] = outcome.Err.Error()
+		vars["summary"] = fmt.Sprintf("%s: failed to %s %s: %s", outcome.Username, outcome.Action, vars["film"], vars["error"])
+	} else if outcome.DryRun {
+		vars["summary"] = fmt.Sprintf("%s: %s (%s, dry run)", outcome.Username, outcome.Action, vars["film"])
+	} else {
+		vars["summary"] = fmt.Sprintf("%s: %s %s", outcome.Username, pastTense(outcome.Event.Action), vars["film"])
+	}
+	vars["kind"] = string(message.Kind)
+	return message, true
+}
+
+// FromStatus returns the message for a change in a worker's login state;
+// only losing the login needs one
+func FromStatus(status letterboxd.Status) (Message, bool) {
+	if status.IsConnected {
+		return Message{}, false
+	}
+	return Message{
+		Kind:     KindAuthLost,
+		Username: status.Username,
+		Time:     status.LastChecked,
+		Vars: map[string]string{
+			"kind":     string(KindAuthLost),
+			"username": status.Username,
+			"summary":  fmt.Sprintf("%s is no longer logged in to Letterboxd", status.Username),
+		},
+	}, true
+}
+
+func filmName(film letterboxd.Film) string {
+	var name = film.Title
+	if name == "" {
+		name = film.ImdbId
+	}
+	if film.Year != 0 {
+		name += fmt.Sprintf(" (%d)", film.Year)
+	}
+	return name
+}
+
+func pastTense(action letterboxd.Action) string {
+	switch action {
+	case letterboxd.FilmWatched:
+		return "marked as watched"
+	case letterboxd.FilmUnwatched:
+		return "marked as unwatched"
+	}
+	return action.String()
+}
+
+// Target is a destination for messages, with its own routing and templates
+type Target struct {
+	sender   sender
+	users    []string
+	kinds    []Kind
+	title    string
+	template string
+}
+
+// TargetOptions configures a Target
+type TargetOptions struct {
+	Type    string // "webhook", "discord", "slack", "ntfy" or "gotify"
+	URL     string
+	Token   string            // ntfy access token or Gotify application token
+	Headers map[string]string // Extra headers for generic webhooks
+	// Letterboxd usernames to notify about, all if empty
+	Users []string
+	// Kinds of message to send, all if empty
+	Events []string
+	// Message templates with {{name}} placeholders, see Message.Vars
+	Title    string
+	Template string
+}
+
+func NewTarget(options TargetOptions) (*Target, error) {
+	if options.URL == "" {
+		return nil, fmt.Errorf("%s notifier needs a url", options.Type)
+	}
+	var target = &Target{
+		users:    options.Users,
+		title:    options.Title,
+		template: options.Template,
+	}
+	if target.title == "" {
+		target.title = _DEFAULT_TITLE
+	}
+	if target.template == "" {
+		target.template = _DEFAULT_TEMPLATE
+	}
+	for _, event := range options.Events {
+		var kind, err = ParseKind(event)
+		if err != nil {
+			return nil, err
+		}
+		target.kinds = append(target.kinds, kind)
+	}
+
+	switch strings.ToLower(options.Type) {
+	case "webhook":
+		target.sender = webhookSender{url: options.URL, headers: options.Headers}
+	case "discord":
+		target.sender = discordSender{url: options.URL}
+	case "slack":
+		target.sender = slackSender{url: options.URL}
+	case "ntfy":
+		target.sender = ntfySender{url: options.URL, token: options.Token}
+	case "gotify":
+		target.sender = gotifySender{url: strings.TrimSuffix(options.URL, "/"), token: options.Token}
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", options.Type)
+	}
+	return target, nil
+}
+
+// accepts returns whether the message is routed to the target
+func (t *Target) accepts(message Message) bool {
+	return (len(t.users) == 0 || slices.Contains(t.users, message.Username)) &&
+		(len(t.kinds) == 0 || slices.Contains(t.kinds, message.Kind))
+}
+
+// Notifier delivers messages to targets in the background, so slow targets
+// never hold up a worker
+type Notifier struct {
+	targets []*Target
+	client  *http.Client
+	queue   chan Message
+}
+
+func New(targets []*Target) *Notifier {
+	var notifier = &Notifier{
+		targets: targets,
+		client:  &http.Client{Timeout: _REQUEST_TIMEOUT},
+		queue:   make(chan Message, _QUEUE_SIZE),
+	}
+	go notifier.run()
+	return notifier
+}
+
+func (n *Notifier) run() {
+	for message := range n.queue {
+		n.deliver(message)
+	}
+}
+
+// Notify queues the message for delivery
+func (n *Notifier) Notify(message Message) {
+	select {
+	case n.queue <- message:
+	default:
+		slog.Warn("Notification queue full, dropping message",
+			slog.String("kind", string(message.Kind)),
+			slog.String("username", message.Username))
+	}
+}
+
+// NotifyOutcome queues the message for a worker outcome, if it needs one
+func (n *Notifier) NotifyOutcome(outcome letterboxd.Outcome) {
+	if message, ok := FromOutcome(outcome); ok {
+		n.Notify(message)
+	}
+}
+
+// NotifyStatus queues the message for a worker's login state, if it needs one
+func (n *Notifier) NotifyStatus(status letterboxd.Status) {
+	if message, ok := FromStatus(status); ok {
+		n.Notify(message)
+	}
+}
+
+func (n *Notifier) deliver(message Message) {
+	for _, target := range n.targets {
+		if !target.accepts(message) {
+			continue
+		}
+		var title = letterboxd.RenderTemplate(target.title, message.Vars)
+		var text = letterboxd.RenderTemplate(target.template, message.Vars)
+		if err := target.sender.send(n.client, title, text, message); err != nil {
+			slog.Warn("Failed to send notification",
+				slog.String("target", target.sender.name()),
+				slog.String("kind", string(message.Kind)),
+				slog.String("error", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package notifier
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"emboxd/letterboxd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedRequest struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+func newReceiver(t *testing.T) (*httptest.Server, *[]receivedRequest) {
+	var received []receivedRequest
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body, _ = io.ReadAll(r.Body)
+		received = append(received, receivedRequest{path: r.URL.Path, header: r.Header, body: string(body)})
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+var matrix = letterboxd.Event{
+	Film:   letterboxd.Film{ImdbId: "tt0133093", Title: "The Matrix", Year: 1999, Server: "plex"},
+	Action: letterboxd.FilmLogged,
+	Time:   time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC),
+}
+
+func TestFromOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome letterboxd.Outcome
+		kind    Kind
+		summary string
+	}{
+		{
+			name:    "Success",
+			outcome: letterboxd.Outcome{Username: "jdoe", Event: matrix, Action: "log film as watched"},
+			kind:    KindSuccess,
+			summary: "jdoe: logged The Matrix (1999)",
+		},
+		{
+			name:    "Dry run",
+			outcome: letterboxd.Outcome{Username: "jdoe", Event: matrix, Action: "would log tt0133093 on 2026-10-15", DryRun: true},
+			kind:    KindSuccess,
+			summary: "jdoe: would log tt0133093 on 2026-10-15 (The Matrix (1999), dry run)",
+		},
+		{
+			name:    "Failure",
+			outcome: letterboxd.Outcome{Username: "jdoe", Event: matrix, Action: "log film as watched", Err: errors.New("timeout")},
+			kind:    KindFailure,
+			summary: "jdoe: failed to log film as watched The Matrix (1999): timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message, ok = FromOutcome(tt.outcome)
+			assert.True(t, ok)
+			assert.Equal(t, tt.kind, message.Kind)
+			assert.Equal(t, tt.summary, message.Vars["summary"])
+			assert.Equal(t, "The Matrix", message.Vars["title"])
+		})
+	}
+
+	var _, ok = FromOutcome(letterboxd.Outcome{Username: "jdoe", Event: matrix, Skipped: "already_logged"})
+	assert.False(t, ok)
+}
+
+func TestFromStatus(t *testing.T) {
+	var message, ok = FromStatus(letterboxd.Status{Username: "jdoe", IsConnected: false})
+	assert.True(t, ok)
+	assert.Equal(t, KindAuthLost, message.Kind)
+	assert.Equal(t, "jdoe is no longer logged in to Letterboxd", message.Vars["summary"])
+
+	_, ok = FromStatus(letterboxd.Status{Username: "jdoe", IsConnected: true})
+	assert.False(t, ok)
+}
+
+func TestTargets(t *testing.T) {
+	var message, _ = FromStatus(letterboxd.Status{Username: "jdoe", IsConnected: false})
+
+	tests := []struct {
+		options TargetOptions
+		path    string
+		header  map[string]string
+		body    string
+	}{
+		{
+			options: TargetOptions{Type: "webhook", Headers: map[string]string{"X-Source": "emboxd"}},
+			header:  map[string]string{"X-Source": "emboxd", "Content-Type": "application/json"},
+			body:    `{"kind":"auth_lost","username":"jdoe","time":"0001-01-01T00:00:00Z","title":"EmBoxd","text":"jdoe is no longer logged in to Letterboxd","vars":{"kind":"auth_lost","summary":"jdoe is no longer logged in to Letterboxd","username":"jdoe"}}`,
+		},
+		{
+			options: TargetOptions{Type: "discord"},
+			body:    `{"content":"jdoe is no longer logged in to Letterboxd","username":"EmBoxd"}`,
+		},
+		{
+			options: TargetOptions{Type: "slack", Title: "EmBoxd: {{kind}}"},
+			body:    `{"text":"*EmBoxd: auth_lost*\njdoe is no longer logged in to Letterboxd"}`,
+		},
+		{
+			options: TargetOptions{Type: "ntfy", Token: "tk_secret", Template: "Log in again as {{username}}"},
+			header:  map[string]string{"Title": "EmBoxd", "Priority": "high", "Authorization": "Bearer tk_secret"},
+			body:    "Log in again as jdoe",
+		},
+		{
+			options: TargetOptions{Type: "gotify", Token: "app-token"},
+			path:    "/message",
+			header:  map[string]string{"X-Gotify-Key": "app-token"},
+			body:    `{"message":"jdoe is no longer logged in to Letterboxd","priority":8,"title":"EmBoxd"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.options.Type, func(t *testing.T) {
+			var server, received = newReceiver(t)
+			tt.options.URL = server.URL
+			var target, err = NewTarget(tt.options)
+			assert.NoError(t, err)
+
+			New([]*Target{target}).deliver(message)
+			if assert.Len(t, *received, 1) {
+				var request = (*received)[0]
+				if tt.path != "" {
+					assert.Equal(t, tt.path, request.path)
+				}
+				for name, value := range tt.header {
+					assert.Equal(t, value, request.header.Get(name))
+				}
+				assert.Equal(t, tt.body, request.body)
+			}
+		})
+	}
+}
+
+func TestRouting(t *testing.T) {
+	var server, received = newReceiver(t)
+	var failuresForJdoe, _ = NewTarget(TargetOptions{Type: "webhook", URL: server.URL + "/jdoe", Users: []string{"jdoe"}, Events: []string{"failure", "auth-lost"}})
+	var everything, _ = NewTarget(TargetOptions{Type: "webhook", URL: server.URL + "/all"})
+	var notifier = New([]*Target{failuresForJdoe, everything})
+
+	var success, _ = FromOutcome(letterboxd.Outcome{Username: "jdoe", Event: matrix, Action: "log film as watched"})
+	var otherFailure, _ = FromOutcome(letterboxd.Outcome{Username: "jane", Event: matrix, Action: "log film as watched", Err: errors.New("timeout")})
+	var authLost, _ = FromStatus(letterboxd.Status{Username: "jdoe"})
+	notifier.deliver(success)
+	notifier.deliver(otherFailure)
+	notifier.deliver(authLost)
+
+	var paths []string
+	for _, request := range *received {
+		paths = append(paths, request.path)
+	}
+	assert.Equal(t, []string{"/all", "/all", "/jdoe", "/all"}, paths)
+}
+
+func TestNewTargetValidation(t *testing.T) {
+	var _, err = NewTarget(TargetOptions{Type: "pager", URL: "http://localhost"})
+	assert.Error(t, err)
+	_, err = NewTarget(TargetOptions{Type: "discord"})
+	assert.Error(t, err)
+	_, err = NewTarget(TargetOptions{Type: "ntfy", URL: "http://localhost", Events: []string{"logged"}})
+	assert.Error(t, err)
+}
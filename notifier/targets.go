@@ -0,0 +1,154 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// sender delivers a rendered message to one kind of service
+type sender interface {
+	name() string
+	send(client *http.Client, title string, text string, message Message) error
+}
+
+// post sends a request and treats any non-2xx response as an error
+func post(client *http.Client, url string, body []byte, headers map[string]string) error {
+	var request, err = http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	var response, doErr = client.Do(request)
+	if doErr != nil {
+		return doErr
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		var detail, _ = io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, response.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+func postJSON(client *http.Client, url string, payload any, headers map[string]string) error {
+	var body, err = json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var allHeaders = map[string]string{"Content-Type": "application/json"}
+	for name, value := range headers {
+		allHeaders[name] = value
+	}
+	return post(client, url, body, allHeaders)
+}
+
+// isAlert returns whether the message is about something going wrong
+func isAlert(message Message) bool {
+	return message.Kind == KindFailure || message.Kind == KindAuthLost
+}
+
+// webhookSender posts the message and all its values as JSON
+type webhookSender struct {
+	url     string
+	headers map[string]string
+}
+
+func (s webhookSender) name() string {
+	return "webhook"
+}
+
+func (s webhookSender) send(client *http.Client, title string, text string, message Message) error {
+	return postJSON(client, s.url, struct {
+		Kind     Kind              `json:"kind"`
+		Username string            `json:"username"`
+		Time     time.Time         `json:"time"`
+		Title    string            `json:"title"`
+		Text     string            `json:"text"`
+		Vars     map[string]string `json:"vars"`
+	}{message.Kind, message.Username, message.Time, title, text, message.Vars}, s.headers)
+}
+
+// discordSender posts to a Discord channel webhook
+type discordSender struct {
+	url string
+}
+
+func (s discordSender) name() string {
+	return "discord"
+}
+
+func (s discordSender) send(client *http.Client, title string, text string, message Message) error {
+	return postJSON(client, s.url, map[string]string{
+		"username": title,
+		"content":  text,
+	}, nil)
+}
+
+// slackSender posts to a Slack incoming webhook
+type slackSender struct {
+	url string
+}
+
+func (s slackSender) name() string {
+	return "slack"
+}
+
+func (s slackSender) send(client *http.Client, title string, text string, message Message) error {
+	return postJSON(client, s.url, map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", title, text),
+	}, nil)
+}
+
+// ntfySender publishes to an ntfy topic URL, e.g. https://ntfy.sh/emboxd
+type ntfySender struct {
+	url   string
+	token string
+}
+
+func (s ntfySender) name() string {
+	return "ntfy"
+}
+
+func (s ntfySender) send(client *http.Client, title string, text string, message Message) error {
+	var headers = map[string]string{
+		"Title": title,
+		"Tags":  "movie_camera",
+	}
+	if isAlert(message) {
+		headers["Priority"] = "high"
+		headers["Tags"] = "warning"
+	}
+	if s.token != "" {
+		headers["Authorization"] = "Bearer " + s.token
+	}
+	return post(client, s.url, []byte(text), headers)
+}
+
+// gotifySender pushes to a Gotify server as an application
+type gotifySender struct {
+	url   string
+	token string
+}
+
+func (s gotifySender) name() string {
+	return "gotify"
+}
+
+func (s gotifySender) send(client *http.Client, title string, text string, message Message) error {
+	var priority = 4
+	if isAlert(message) {
+		priority = 8
+	}
+	return postJSON(client, s.url+"/message", map[string]any{
+		"title":    title,
+		"message":  text,
+		"priority": priority,
+	}, map[string]string{"X-Gotify-Key": s.token})
+}
@@ -0,0 +1,285 @@
+package polling
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"emboxd/history"
+	"emboxd/mediaserver"
+	"emboxd/notification"
+)
+
+// trackedEmbySession is a session seen in the previous poll
+type trackedEmbySession struct {
+	session   mediaserver.EmbySession
+	seenAt    time.Time
+	processor *notification.Processor
+}
+
+// EmbyPoller synthesises the notifications of postEmbyWebhook from an Emby
+// (or Jellyfin) server's sessions and played items
+type EmbyPoller struct {
+	emby                *mediaserver.Emby
+	processorByUsername map[string]*notification.Processor
+	eventHistory        *history.Store
+	// File the played-item watermarks are kept in across restarts, if any
+	statePath string
+
+	lock        sync.Mutex
+	sessions    map[string]trackedEmbySession // By session and item ID
+	playedSince map[string]time.Time          // Last played time already handled, by username
+	sessionSeen map[string]time.Time          // Last time a session was seen, by username and item ID
+	now         func() time.Time
+}
+
+func NewEmbyPoller(
+	emby *mediaserver.Emby,
+	processorByUsername map[string]*notification.Processor,
+	eventHistory *history.Store,
+	statePath string,
+) *EmbyPoller {
+	var poller = &EmbyPoller{
+		emby:                emby,
+		processorByUsername: processorByUsername,
+		eventHistory:        eventHistory,
+		statePath:           statePath,
+		sessions:            make(map[string]trackedEmbySession),
+		playedSince:         make(map[string]time.Time),
+		sessionSeen:         make(map[string]time.Time),
+		now:                 time.Now,
+	}
+	poller.loadState()
+	return poller
+}
+
+func (p *EmbyPoller) loadState() {
+	if p.statePath == "" {
+		return
+	}
+	var data, err = os.ReadFile(p.statePath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &p.playedSince)
+	}
+	if err != nil {
+		slog.Warn("Failed to load Emby polling state, starting afresh",
+			slog.String("path", p.statePath),
+			slog.String("error", err.Error()))
+	}
+}
+
+func (p *EmbyPoller) saveState() {
+	if p.statePath == "" {
+		return
+	}
+	var data, err = json.Marshal(p.playedSince)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(p.statePath), 0755)
+	}
+	if err == nil {
+		var tmpPath = p.statePath + ".tmp"
+		if err = os.WriteFile(tmpPath, data, 0644); err == nil {
+			err = os.Rename(tmpPath, p.statePath)
+		}
+	}
+	if err != nil {
+		slog.Warn("Failed to save Emby polling state",
+			slog.String("path", p.statePath),
+			slog.String("error", err.Error()))
+	}
+}
+
+// Start polls the server in the background at the given interval
+func (p *EmbyPoller) Start(interval time.Duration) {
+	go func() {
+		for {
+			if err := p.Poll(); err != nil {
+				slog.Warn("Failed to poll Emby", slog.String("error", err.Error()))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Poll sends notifications for session changes since the previous poll,
+// then for items played since they were last checked
+func (p *EmbyPoller) Poll() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := p.pollSessions(); err != nil {
+		return err
+	}
+	p.pollPlayed()
+	return nil
+}
+
+func (p *EmbyPoller) pollSessions() error {
+	var sessions, err = p.emby.Sessions()
+	if err != nil {
+		return err
+	}
+	var now = p.now()
+
+	var current = make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		if session.Item.Type != "Movie" {
+			continue
+		}
+		var key = session.Id + "/" + session.Item.ItemId
+		current[key] = true
+
+		var tracked, known = p.sessions[key]
+		switch {
+		case !known:
+			tracked = trackedEmbySession{processor: p.processorByUsername[session.Username]}
+			if tracked.processor != nil && !session.Paused {
+				p.sendPlayback(tracked.processor, session, true, session.Position, now)
+			}
+		case tracked.processor == nil:
+		case tracked.session.Paused != session.Paused:
+			p.sendPlayback(tracked.processor, session, !session.Paused, session.Position, now)
+		case !session.Paused:
+			// Position update: report progress, so seeking between polls
+			// leaves a gap in the watched coverage
+			p.sendPlayback(tracked.processor, session, true, session.Position, now)
+		}
+
+		tracked.session = session
+		tracked.seenAt = now
+		p.sessions[key] = tracked
+		p.sessionSeen[session.Username+"/"+session.Item.ItemId] = now
+	}
+
+	for key, tracked := range p.sessions {
+		if current[key] {
+			continue
+		}
+		delete(p.sessions, key)
+		if tracked.processor == nil {
+			continue
+		}
+
+		// The session ended some time since the last poll, estimate how far it got
+		var position = tracked.session.Position
+		if !tracked.session.Paused {
+			position = min(position+now.Sub(tracked.seenAt), tracked.session.Item.Runtime)
+		}
+		p.sendPlayback(tracked.processor, tracked.session, false, position, now)
+		p.sessionSeen[tracked.session.Username+"/"+tracked.session.Item.ItemId] = now
+	}
+	return nil
+}
+
+// pollPlayed sends watched notifications for items marked played since the
+// previous check, including while emboxd wasn't running. Items with a session
+// seen since then were already handled by their playback notifications.
+func (p *EmbyPoller) pollPlayed() {
+	var changed bool
+	for username, processor := range p.processorByUsername {
+		var userId, err = p.emby.UserId(username)
+		if err != nil {
+			slog.Warn("Failed to look up Emby user", slog.String("username", username), slog.String("error", err.Error()))
+			continue
+		}
+
+		var since, checked = p.playedSince[username]
+		var items, playedErr = p.emby.PlayedSince(userId, since)
+		if playedErr != nil {
+			slog.Warn("Failed to fetch played Emby items", slog.String("username", username), slog.String("error", playedErr.Error()))
+			continue
+		}
+
+		// Oldest first, so the watermark only moves past handled items
+		slices.Reverse(items)
+		for _, item := range items {
+			if checked && !p.sessionSeenSince(username, item.ItemId, since) {
+				var watched = notification.WatchedNotification{
+					Metadata: embyMetadata(username, item, "", "", item.LastPlayed),
+					Watched:  true,
+					Runtime:  item.Runtime,
+				}
+				var decision = processor.ProcessWatchedNotification(watched)
+				p.logNotification(watched, decision)
+			}
+			if item.LastPlayed.After(since) {
+				since = item.LastPlayed
+			}
+		}
+
+		// The first check only sets the watermark rather than replaying the whole history
+		if !checked && since.IsZero() {
+			since = p.now()
+		}
+		if !checked || len(items) > 0 {
+			p.playedSince[username] = since
+			changed = true
+		}
+		p.forgetSessionsBefore(username, since)
+	}
+	if changed {
+		p.saveState()
+	}
+}
+
+// sessionSeenSince returns true if a session of the user played the item at or
+// after the time
+func (p *EmbyPoller) sessionSeenSince(username string, itemId string, since time.Time) bool {
+	var seenAt, seen = p.sessionSeen[username+"/"+itemId]
+	return seen && !seenAt.Before(since)
+}
+
+// forgetSessionsBefore drops the user's sessions seen before the watermark,
+// which can't match a played item anymore
+func (p *EmbyPoller) forgetSessionsBefore(username string, since time.Time) {
+	for key, seenAt := range p.sessionSeen {
+		if strings.HasPrefix(key, username+"/") && seenAt.Before(since) {
+			delete(p.sessionSeen, key)
+		}
+	}
+}
+
+func (p *EmbyPoller) sendPlayback(processor *notification.Processor, session mediaserver.EmbySession, playing bool, position time.Duration, now time.Time) {
+	var playback = notification.PlaybackNotification{
+		Metadata: embyMetadata(session.Username, session.Item, session.Client, session.DeviceName, now),
+		Playing:  playing,
+		Position: position,
+		Runtime:  session.Item.Runtime,
+	}
+	var decision = processor.ProcessPlaybackNotification(playback)
+	p.logNotification(playback, decision)
+}
+
+func (p *EmbyPoller) logNotification(notif interface{}, decision notification.Decision) {
+	if p.eventHistory == nil {
+		return
+	}
+	var event = history.FromNotification(notif, history.SourceEmby, history.StatusSuccess, 0, nil)
+	event.Details["polled"] = true
+	p.eventHistory.Add(event.WithDecision(decision))
+}
+
+func embyMetadata(username string, item mediaserver.EmbyItem, client string, device string, at time.Time) notification.Metadata {
+	return notification.Metadata{
+		Server:   notification.Emby,
+		Username: username,
+		ImdbId:   item.ImdbId,
+		TmdbId:   item.TmdbId,
+		ItemId:   item.ItemId,
+		Title:    item.Title,
+		Year:     item.Year,
+		Time:     at,
+
+		Player:     client,
+		Device:     device,
+		Resolution: notification.Resolution(item.Height),
+	}
+}
@@ -0,0 +1,162 @@
+package polling
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"emboxd/letterboxd"
+	"emboxd/mediaserver"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbyPoller(t *testing.T) {
+	var server = newRecordedServer(t)
+	var events []letterboxd.Event
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		events = append(events, event)
+	})
+
+	var poller = NewEmbyPoller(
+		mediaserver.NewEmby(server.URL, "key"),
+		map[string]*notification.Processor{"JohnDoe": &processor},
+		nil,
+		"",
+	)
+	var start = time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	var now = start
+	poller.now = func() time.Time { return now }
+
+	server.respond("/Users", "testdata/emby_users.json")
+	server.respond("/Users/d4c3b2a1/Items", "testdata/emby_played_none.json")
+
+	// Playback starts
+	server.respond("/Sessions", "testdata/emby_sessions_playing.json")
+	assert.NoError(t, poller.Poll())
+	assert.Empty(t, events)
+
+	// Still playing an hour later
+	now = start.Add(time.Hour)
+	server.respond("/Sessions", "testdata/emby_sessions_progress.json")
+	assert.NoError(t, poller.Poll())
+	assert.Empty(t, events)
+
+	// Paused near the end after watching most of it, which logs the film
+	now = start.Add(8000 * time.Second)
+	server.respond("/Sessions", "testdata/emby_sessions_paused.json")
+	assert.NoError(t, poller.Poll())
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, letterboxd.FilmLogged, events[0].Action)
+		assert.Equal(t, "tt0133093", events[0].ImdbId)
+		assert.Equal(t, "603", events[0].TmdbId)
+		assert.Equal(t, "Emby for Android", events[0].Player)
+		assert.Equal(t, "Pixel 8", events[0].Device)
+		assert.Equal(t, "1080p", events[0].Resolution)
+		assert.Equal(t, now, events[0].Time)
+	}
+
+	// Session ends without further changes, and Emby marks the film played,
+	// which was already logged from the session
+	now = now.Add(time.Minute)
+	server.respond("/Sessions", "testdata/emby_sessions_empty.json")
+	server.respond("/Users/d4c3b2a1/Items", "testdata/emby_played_matrix.json")
+	assert.NoError(t, poller.Poll())
+	assert.Len(t, events, 1)
+	now = now.Add(time.Minute)
+	assert.NoError(t, poller.Poll())
+	assert.Len(t, events, 1)
+}
+
+func TestEmbyPollerEstimatesStopPosition(t *testing.T) {
+	var server = newRecordedServer(t)
+	var events []letterboxd.Event
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		events = append(events, event)
+	})
+
+	var poller = NewEmbyPoller(
+		mediaserver.NewEmby(server.URL, "key"),
+		map[string]*notification.Processor{"JohnDoe": &processor},
+		nil,
+		"",
+	)
+	var start = time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	var now = start
+	poller.now = func() time.Time { return now }
+
+	server.respond("/Users", "testdata/emby_users.json")
+	server.respond("/Users/d4c3b2a1/Items", "testdata/emby_played_none.json")
+	server.respond("/Sessions", "testdata/emby_sessions_playing.json")
+	assert.NoError(t, poller.Poll())
+
+	// The session disappears while playing; the stop position is estimated
+	// from the time since the last poll, so the film is logged
+	now = start.Add(8000 * time.Second)
+	server.respond("/Sessions", "testdata/emby_sessions_empty.json")
+	assert.NoError(t, poller.Poll())
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, letterboxd.FilmLogged, events[0].Action)
+	}
+}
+
+func TestEmbyPollerCatchesUpOnPlayedItems(t *testing.T) {
+	var server = newRecordedServer(t)
+	var events []letterboxd.Event
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		events = append(events, event)
+	})
+
+	// Items played before the stored watermark were already handled
+	var statePath = filepath.Join(t.TempDir(), "emby-poll.json")
+	assert.NoError(t, os.WriteFile(statePath, []byte(`{"JohnDoe":"2026-10-15T00:00:00Z"}`), 0644))
+
+	var newPoller = func() *EmbyPoller {
+		return NewEmbyPoller(
+			mediaserver.NewEmby(server.URL, "key"),
+			map[string]*notification.Processor{"JohnDoe": &processor},
+			nil,
+			statePath,
+		)
+	}
+	server.respond("/Users", "testdata/emby_users.json")
+	server.respond("/Users/d4c3b2a1/Items", "testdata/emby_played_offline.json")
+	server.respond("/Sessions", "testdata/emby_sessions_empty.json")
+
+	assert.NoError(t, newPoller().Poll())
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, letterboxd.FilmWatched, events[0].Action)
+		assert.Equal(t, "tt0078748", events[0].ImdbId)
+		assert.Equal(t, time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC), events[0].Time)
+		assert.Equal(t, letterboxd.FilmWatched, events[1].Action)
+		assert.Equal(t, "tt0113277", events[1].ImdbId)
+	}
+
+	// The watermark survives a restart, so nothing is sent twice
+	assert.NoError(t, newPoller().Poll())
+	assert.Len(t, events, 2)
+}
+
+func TestEmbyPollerFirstRunDoesNotBackfill(t *testing.T) {
+	var server = newRecordedServer(t)
+	var events []letterboxd.Event
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		events = append(events, event)
+	})
+
+	var poller = NewEmbyPoller(
+		mediaserver.NewEmby(server.URL, "key"),
+		map[string]*notification.Processor{"JohnDoe": &processor},
+		nil,
+		"",
+	)
+	server.respond("/Users", "testdata/emby_users.json")
+	server.respond("/Users/d4c3b2a1/Items", "testdata/emby_played_offline.json")
+	server.respond("/Sessions", "testdata/emby_sessions_empty.json")
+
+	assert.NoError(t, poller.Poll())
+	assert.Empty(t, events)
+	assert.Equal(t, time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC), poller.playedSince["JohnDoe"])
+}
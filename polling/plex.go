@@ -0,0 +1,217 @@
+package polling
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"emboxd/history"
+	"emboxd/mediaserver"
+	"emboxd/notification"
+)
+
+// trackedPlexSession is a session seen in the previous poll
+type trackedPlexSession struct {
+	session   mediaserver.PlexSession
+	item      mediaserver.PlexItem // Library item as of the session start, with GUIDs
+	seenAt    time.Time
+	processor *notification.Processor // Nil for users without a Letterboxd account
+}
+
+// PlexPoller synthesises playback and watched notifications from a Plex
+// server's sessions, for servers that can't send webhooks
+type PlexPoller struct {
+	plex                 *mediaserver.Plex
+	tokensByAccountID    map[string]string
+	processorByAccountID map[string]*notification.Processor
+	processorByUsername  map[string]*notification.Processor
+	eventHistory         *history.Store
+
+	lock     sync.Mutex
+	sessions map[string]trackedPlexSession // By session ID
+	now      func() time.Time
+}
+
+// NewPlexPoller creates a poller for the Plex server. Tokens of individual
+// users, by account ID, are used to detect their scrobbles; the server token
+// only reflects the owner's views, so other users' scrobbles aren't checked.
+func NewPlexPoller(
+	plex *mediaserver.Plex,
+	tokensByAccountID map[string]string,
+	processorByAccountID map[string]*notification.Processor,
+	processorByUsername map[string]*notification.Processor,
+	eventHistory *history.Store,
+) *PlexPoller {
+	return &PlexPoller{
+		plex:                 plex,
+		tokensByAccountID:    tokensByAccountID,
+		processorByAccountID: processorByAccountID,
+		processorByUsername:  processorByUsername,
+		eventHistory:         eventHistory,
+		sessions:             make(map[string]trackedPlexSession),
+		now:                  time.Now,
+	}
+}
+
+// Start polls the server's sessions in the background at the given interval
+func (p *PlexPoller) Start(interval time.Duration) {
+	go func() {
+		for {
+			if err := p.Poll(); err != nil {
+				slog.Warn("Failed to poll Plex sessions", slog.String("error", err.Error()))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// client returns a client acting as the session's user where possible
+func (p *PlexPoller) client(accountID string) *mediaserver.Plex {
+	if token, ok := p.tokensByAccountID[accountID]; ok && token != "" {
+		return p.plex.WithToken(token)
+	}
+	return p.plex
+}
+
+func (p *PlexPoller) processorFor(session mediaserver.PlexSession) *notification.Processor {
+	if processor, ok := p.processorByAccountID[session.AccountId]; ok {
+		return processor
+	}
+	return p.processorByUsername[session.Username]
+}
+
+// Poll compares the server's sessions with the previous poll and sends the
+// notifications for any changes
+func (p *PlexPoller) Poll() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var sessions, err = p.plex.Sessions()
+	if err != nil {
+		return err
+	}
+	var now = p.now()
+
+	var current = make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		if session.Type != "movie" {
+			continue
+		}
+		current[session.Id] = true
+
+		var tracked, known = p.sessions[session.Id]
+		if !known {
+			tracked = trackedPlexSession{session: session, processor: p.processorFor(session)}
+			if tracked.processor != nil {
+				var item, itemErr = p.client(session.AccountId).Item(session.ItemId)
+				if itemErr != nil {
+					slog.Warn("Failed to look up Plex session item, retrying next poll",
+						slog.String("ratingKey", session.ItemId),
+						slog.String("error", itemErr.Error()))
+					delete(current, session.Id)
+					continue
+				}
+				tracked.item = item
+				if isPlaying(session.State) {
+					p.sendPlayback(tracked, session, true, session.ViewOffset, now)
+				}
+			}
+		} else if tracked.processor != nil && isPlaying(tracked.session.State) != isPlaying(session.State) {
+			p.sendPlayback(tracked, session, isPlaying(session.State), session.ViewOffset, now)
+		}
+
+		tracked.session = session
+		tracked.seenAt = now
+		p.sessions[session.Id] = tracked
+	}
+
+	for id, tracked := range p.sessions {
+		if current[id] {
+			continue
+		}
+		delete(p.sessions, id)
+		if tracked.processor != nil {
+			p.endSession(tracked, now)
+		}
+	}
+	return nil
+}
+
+// endSession sends the stop notification for a finished session, then a
+// watched notification if Plex counted it as a view
+func (p *PlexPoller) endSession(tracked trackedPlexSession, now time.Time) {
+	// The session ended some time since the last poll, estimate how far it got
+	var position = tracked.session.ViewOffset
+	if isPlaying(tracked.session.State) {
+		position = min(position+now.Sub(tracked.seenAt), tracked.item.Duration)
+	}
+	p.sendPlayback(tracked, tracked.session, false, position, now)
+
+	var token = p.tokensByAccountID[tracked.session.AccountId]
+	if token == "" {
+		slog.Warn("Skipping Plex scrobble check without a plex.token for the user",
+			slog.String("username", tracked.session.Username),
+			slog.String("ratingKey", tracked.item.ItemId))
+		return
+	}
+	var item, err = p.plex.WithToken(token).Item(tracked.item.ItemId)
+	if err != nil {
+		slog.Warn("Failed to check Plex item for scrobble",
+			slog.String("ratingKey", tracked.item.ItemId),
+			slog.String("error", err.Error()))
+		return
+	}
+	if item.ViewCount > tracked.item.ViewCount || item.LastViewedAt.After(tracked.item.LastViewedAt) {
+		var watched = notification.WatchedNotification{
+			Metadata: plexSessionMetadata(tracked, now),
+			Watched:  true,
+			Runtime:  tracked.item.Duration,
+		}
+		var decision = tracked.processor.ProcessWatchedNotification(watched)
+		p.logNotification(watched, decision)
+	}
+}
+
+func (p *PlexPoller) sendPlayback(tracked trackedPlexSession, session mediaserver.PlexSession, playing bool, position time.Duration, now time.Time) {
+	tracked.session = session
+	var playback = notification.PlaybackNotification{
+		Metadata: plexSessionMetadata(tracked, now),
+		Playing:  playing,
+		Position: position,
+		Runtime:  tracked.item.Duration,
+	}
+	var decision = tracked.processor.ProcessPlaybackNotification(playback)
+	p.logNotification(playback, decision)
+}
+
+func (p *PlexPoller) logNotification(notif interface{}, decision notification.Decision) {
+	if p.eventHistory == nil {
+		return
+	}
+	var event = history.FromNotification(notif, history.SourcePlex, history.StatusSuccess, 0, nil)
+	event.Details["polled"] = true
+	p.eventHistory.Add(event.WithDecision(decision))
+}
+
+// isPlaying returns true for sessions that are making progress
+func isPlaying(state string) bool {
+	return state == "playing" || state == "buffering"
+}
+
+func plexSessionMetadata(tracked trackedPlexSession, now time.Time) notification.Metadata {
+	return notification.Metadata{
+		Server:   notification.Plex,
+		Username: tracked.session.Username,
+		ImdbId:   tracked.item.ImdbId,
+		TmdbId:   tracked.item.TmdbId,
+		ItemId:   tracked.item.ItemId,
+		Title:    tracked.item.Title,
+		Year:     tracked.item.Year,
+		Time:     now,
+
+		Player:     tracked.session.Player,
+		Device:     tracked.session.Device,
+		Library:    tracked.item.Library,
+		Resolution: notification.PlexResolution(tracked.session.VideoResolution, 0),
+	}
+}
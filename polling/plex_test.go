@@ -0,0 +1,164 @@
+package polling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"emboxd/letterboxd"
+	"emboxd/mediaserver"
+	"emboxd/notification"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordedServer serves recorded responses by path, which tests swap between polls
+type recordedServer struct {
+	*httptest.Server
+	lock      sync.Mutex
+	responses map[string]string // Fixture filename by path
+}
+
+func newRecordedServer(t *testing.T) *recordedServer {
+	var server = &recordedServer{responses: make(map[string]string)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.lock.Lock()
+		var fixture, ok = server.responses[r.URL.Path]
+		server.lock.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var data, err = os.ReadFile(fixture)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *recordedServer) respond(path string, fixture string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.responses[path] = fixture
+}
+
+func TestPlexPoller(t *testing.T) {
+	var server = newRecordedServer(t)
+	var events []letterboxd.Event
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		events = append(events, event)
+	})
+
+	var poller = NewPlexPoller(
+		mediaserver.NewPlex(server.URL, "token"),
+		map[string]string{"12345": "user-token"},
+		map[string]*notification.Processor{"12345": &processor},
+		nil,
+		nil,
+	)
+	var start = time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	var now = start
+	poller.now = func() time.Time { return now }
+
+	// Playback starts
+	server.respond("/status/sessions", "testdata/plex_sessions_playing.json")
+	server.respond("/library/metadata/42", "testdata/plex_metadata_unwatched.json")
+	assert.NoError(t, poller.Poll())
+	assert.Empty(t, events)
+
+	// Paused near the end after watching most of it, which logs the film
+	now = start.Add(8000 * time.Second)
+	server.respond("/status/sessions", "testdata/plex_sessions_paused.json")
+	assert.NoError(t, poller.Poll())
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, letterboxd.FilmLogged, events[0].Action)
+		assert.Equal(t, "tt0133093", events[0].ImdbId)
+		assert.Equal(t, "603", events[0].TmdbId)
+		assert.Equal(t, "Living Room", events[0].Device)
+		assert.Equal(t, "1080p", events[0].Resolution)
+		assert.Equal(t, now, events[0].Time)
+	}
+
+	// Session ends and Plex counted the view
+	now = now.Add(time.Minute)
+	server.respond("/status/sessions", "testdata/plex_sessions_empty.json")
+	server.respond("/library/metadata/42", "testdata/plex_metadata_watched.json")
+	assert.NoError(t, poller.Poll())
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, letterboxd.FilmWatched, events[1].Action)
+		assert.Equal(t, "tt0133093", events[1].ImdbId)
+	}
+
+	// Nothing changes once the session is gone
+	assert.NoError(t, poller.Poll())
+	assert.Len(t, events, 2)
+}
+
+func TestPlexPollerEstimatesStopPosition(t *testing.T) {
+	var server = newRecordedServer(t)
+	var events []letterboxd.Event
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		events = append(events, event)
+	})
+
+	var poller = NewPlexPoller(
+		mediaserver.NewPlex(server.URL, "token"),
+		nil,
+		nil,
+		map[string]*notification.Processor{"JohnDoe": &processor},
+		nil,
+	)
+	var start = time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	var now = start
+	poller.now = func() time.Time { return now }
+
+	server.respond("/status/sessions", "testdata/plex_sessions_playing.json")
+	server.respond("/library/metadata/42", "testdata/plex_metadata_unwatched.json")
+	assert.NoError(t, poller.Poll())
+
+	// The session disappears while playing; the stop position is estimated
+	// from the time since the last poll, so the film is logged
+	now = start.Add(8000 * time.Second)
+	server.respond("/status/sessions", "testdata/plex_sessions_empty.json")
+	assert.NoError(t, poller.Poll())
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, letterboxd.FilmLogged, events[0].Action)
+	}
+}
+
+func TestPlexPollerSkipsScrobbleCheckWithoutUserToken(t *testing.T) {
+	var server = newRecordedServer(t)
+	var events []letterboxd.Event
+	var processor = notification.NewProcessor(func(event letterboxd.Event) {
+		events = append(events, event)
+	})
+
+	var poller = NewPlexPoller(
+		mediaserver.NewPlex(server.URL, "token"),
+		nil,
+		map[string]*notification.Processor{"12345": &processor},
+		nil,
+		nil,
+	)
+	var start = time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	var now = start
+	poller.now = func() time.Time { return now }
+
+	server.respond("/status/sessions", "testdata/plex_sessions_playing.json")
+	server.respond("/library/metadata/42", "testdata/plex_metadata_unwatched.json")
+	assert.NoError(t, poller.Poll())
+
+	// The server token only shows the owner's views, which aren't this user's
+	now = start.Add(10 * time.Minute)
+	server.respond("/status/sessions", "testdata/plex_sessions_empty.json")
+	server.respond("/library/metadata/42", "testdata/plex_metadata_watched.json")
+	assert.NoError(t, poller.Poll())
+	assert.Empty(t, events)
+}
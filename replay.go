@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"emboxd/api"
+	"emboxd/capture"
+	"emboxd/config"
+	"emboxd/history"
+	"emboxd/letterboxd"
+	"emboxd/logging"
+	"emboxd/mapping"
+	"emboxd/notification"
+)
+
+// runReplay implements `emboxd replay <file>`, feeding captured webhooks back
+// through the API handlers without changing Letterboxd, and returns the exit code
+func runReplay(args []string) int {
+	var flags = flag.NewFlagSet("replay", flag.ExitOnError)
+	var verbose bool
+	var configFilename string
+	var dataDir string
+	var speed float64
+	var useLetterboxd bool
+	var wait time.Duration
+	flags.BoolVar(&verbose, "v", false, "Enable debug logging")
+	flags.BoolVar(&verbose, "verbose", false, "Enable debug logging")
+	flags.StringVar(&configFilename, "c", "config/config.yaml", "Path to configuration file")
+	flags.StringVar(&configFilename, "config", "config/config.yaml", "Path to configuration file")
+	flags.StringVar(&dataDir, "data-dir", "data", "Directory for application data such as mappings.yaml")
+	flags.Float64Var(&speed, "speed", 1, "Pace relative to the original requests, e.g. 60 for a minute per second, 0 for no waiting")
+	flags.BoolVar(&useLetterboxd, "letterboxd", false, "Verify actions against Letterboxd in dry-run mode instead of only printing them")
+	flags.DurationVar(&wait, "wait", 5*time.Minute, "How long to wait for Letterboxd dry runs to finish")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: emboxd replay [options] <file>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	logging.Configure(verbose)
+	var conf = config.Load(configFilename)
+
+	var requests, readErr = capture.Read(flags.Arg(0))
+	if readErr != nil {
+		slog.Error("Failed to read captured webhooks", slog.String("error", readErr.Error()))
+		return 1
+	}
+	var mappings, mappingsErr = mapping.NewStore(filepath.Join(dataDir, "mappings.yaml"))
+	if mappingsErr != nil {
+		slog.Error("Failed to load mappings", slog.String("error", mappingsErr.Error()))
+		return 1
+	}
+
+	if useLetterboxd {
+		if err := letterboxd.Launch(); err != nil {
+			slog.Error("Failed to launch browser", slog.String("error", err.Error()))
+			return 1
+		}
+	}
+
+	var eventHistory = history.NewStore(len(requests) + 100)
+	// At most two events per webhook, e.g. an Emby stop played to completion
+	// is both a playback and a watched notification
+	var outcomes = make(chan letterboxd.Outcome, 2*len(requests))
+	var pending int
+
+	var notificationProcessorByEmbyUsername = make(map[string]*notification.Processor, len(conf.Users))
+	var notificationProcessorByPlexUsername = make(map[string]*notification.Processor, len(conf.Users))
+	var notificationProcessorByPlexAccountID = make(map[string]*notification.Processor, len(conf.Users))
+	var letterboxdWorkers = make(map[string]*letterboxd.Worker, len(conf.Users))
+	for _, user := range conf.Users {
+		var letterboxdUsername = user.Letterboxd.Username
+		var letterboxdWorker, workerExists = letterboxdWorkers[letterboxdUsername]
+		if useLetterboxd && !workerExists {
+			var worker = letterboxd.NewWorker(letterboxdUsername, user.Letterboxd.Password, letterboxd.WorkerOptions{
+				LogFilms:            user.Letterboxd.LogFilms,
+				DuplicateWindow:     duplicateWindow(user.Letterboxd.DuplicateWindow),
+				DryRun:              true,
+				RemoveFromWatchlist: user.Letterboxd.RemoveFromWatchlist,
+				SyncLikes:           user.Letterboxd.SyncLikes,
+				ListTemplate:        user.Letterboxd.ListTemplate,
+				DiaryTagsTemplate:   user.Letterboxd.DiaryTags,
+				DiaryReviewTemplate: user.Letterboxd.DiaryReview,
+				Mappings:            mappings,
+				OnOutcome: func(outcome letterboxd.Outcome) {
+					outcomes <- outcome
+				},
+			})
+			worker.Start()
+			letterboxdWorker = &worker
+			letterboxdWorkers[letterboxdUsername] = letterboxdWorker
+		}
+
+		var notificationProcessor = notification.NewProcessor(func(event letterboxd.Event) {
+			slog.Info("Replayed decision",
+				slog.String("username", letterboxdUsername),
+				slog.String("action", event.Action.String()),
+				slog.String("imdbId", event.ImdbId),
+				slog.String("title", event.Title),
+				slog.Time("eventTime", event.Time))
+			if letterboxdWorker != nil {
+				pending++
+				letterboxdWorker.HandleEvent(event)
+			}
+		})
+		if user.Emby.Username != "" {
+			notificationProcessorByEmbyUsername[user.Emby.Username] = &notificationProcessor
+		}
+		if user.Plex.Username != "" {
+			notificationProcessorByPlexUsername[user.Plex.Username] = &notificationProcessor
+		}
+		if user.Plex.ID != "" {
+			notificationProcessorByPlexAccountID[user.Plex.ID] = &notificationProcessor
+		}
+	}
+
+	var app = api.New(
+		notificationProcessorByEmbyUsername,
+		notificationProcessorByPlexUsername,
+		notificationProcessorByPlexAccountID,
+		letterboxdWorkers,
+		mappings,
+		eventHistory,
+	)
+	// Requests are handled as if received at their original times, so a
+	// time-compressed replay makes the same decisions
+	var received time.Time
+	app.SetClock(func() time.Time { return received })
+
+	var replayer = capture.NewReplayer(app.Handler(), speed)
+	replayer.OnRequest = func(request capture.Request) {
+		received = request.Time
+		slog.Info("Replaying webhook",
+			slog.String("path", request.Path),
+			slog.Time("received", request.Time))
+	}
+	var results, replayErr = replayer.Replay(requests)
+	for _, result := range results {
+		if result.Status >= 400 {
+			slog.Warn("Replayed webhook failed",
+				slog.String("path", result.Request.Path),
+				slog.Time("received", result.Request.Time),
+				slog.Int("status", result.Status),
+				slog.String("body", result.Body))
+		}
+	}
+	if replayErr != nil {
+		slog.Error("Failed to replay webhooks", slog.String("error", replayErr.Error()))
+		return 1
+	}
+
+	var timeout = time.After(wait)
+	for ; pending > 0; pending-- {
+		select {
+		case outcome := <-outcomes:
+			var attrs = []any{
+				slog.String("username", outcome.Username),
+				slog.String("action", outcome.Action),
+				slog.String("imdbId", outcome.Event.ImdbId),
+			}
+			if outcome.Skipped != "" {
+				attrs = append(attrs, slog.String("skipped", outcome.Skipped))
+			}
+			if outcome.Err != nil {
+				attrs = append(attrs, slog.String("error", outcome.Err.Error()))
+			}
+			slog.Info("Letterboxd dry run", attrs...)
+		case <-timeout:
+			slog.Warn("Gave up waiting for Letterboxd dry runs", slog.Int("pending", pending))
+			return 1
+		}
+	}
+
+	slog.Info("Replay complete", slog.Int("webhooks", len(results)))
+	return 0
+}